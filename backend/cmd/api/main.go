@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/gin-swagger"
@@ -16,6 +17,7 @@ import (
 	"github.com/forever-free1/telegram-go/backend/internal/middleware"
 	"github.com/forever-free1/telegram-go/backend/internal/model"
 	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/search"
 	"github.com/forever-free1/telegram-go/backend/internal/service"
 	"github.com/forever-free1/telegram-go/backend/internal/websocket"
 	"github.com/forever-free1/telegram-go/backend/pkg/snowflake"
@@ -91,34 +93,48 @@ func main() {
 	chatRepo := repository.NewChatRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
 	contactRepo := repository.NewContactRepository(db)
+	scheduledRepo := repository.NewScheduledMessageRepository(db)
+	pollRepo := repository.NewPollRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+
+	// 消息搜索使用 messages 表上的 FULLTEXT 索引
+	searchIndex := search.NewMySQLIndex(db)
 
 	// Setup services
 	authService := service.NewAuthService(userRepo, sessionRepo, &cfg.JWT, logger)
-	messageService := service.NewMessageService(messageRepo, chatRepo, userRepo, logger)
+	messageService := service.NewMessageService(messageRepo, chatRepo, pollRepo, userRepo, logger)
 	chatService := service.NewChatService(chatRepo, userRepo, logger)
+	inviteService := service.NewInviteService(inviteRepo, chatRepo, chatService, logger)
 	fileService := service.NewFileService(cfg.Upload.Path, cfg.Upload.BaseURL, logger, service.WithMaxSize(cfg.Upload.MaxSize))
 	notificationService := service.NewNotificationService(logger)
 	contactService := service.NewContactService(userRepo, contactRepo, logger)
+	scheduledService := service.NewScheduledMessageService(scheduledRepo, messageService, logger)
+	pollService := service.NewPollService(pollRepo, messageService, logger)
+	searchService := service.NewSearchService(searchIndex, chatRepo, messageRepo, logger)
+	messageSweeper := service.NewMessageSweeper(messageRepo, messageService, fileService, logger)
 
 	// Setup WebSocket hub (must be created before handlers)
 	wsHub := websocket.NewHub()
 
-	// 设置 Hub 的在线用户检查回调
-	wsHub.SetOnlineChecker(func(userID int64) bool {
-		return notificationService.IsUserOnline(userID)
-	})
+	// 离线推送前通过 Hub 检查用户是否在线（包括其他节点上的连接）
+	notificationService.SetPresenceChecker(wsHub.IsUserOnline)
 
 	// Setup handlers
 	authHandler := handler.NewAuthHandler(authService)
-	messageHandler := handler.NewMessageHandler(messageService, wsHub)
+	messageHandler := handler.NewMessageHandler(messageService, scheduledService, pollService, wsHub)
 	chatHandler := handler.NewChatHandler(chatService)
+	inviteHandler := handler.NewInviteHandler(inviteService)
 	uploadHandler := handler.NewUploadHandler(fileService)
 	deviceHandler := handler.NewDeviceHandler(notificationService)
 	contactHandler := handler.NewContactHandler(contactService)
+	searchHandler := handler.NewSearchHandler(searchService)
 
 	// 设置消息服务使用离线推送
 	messageService.SetPushService(notificationService)
 
+	// 消息发送、编辑和删除时同步更新搜索索引
+	messageService.SetSearchIndex(searchIndex)
+
 	// 设置消息广播器：MessageService -> Hub
 	// 当 REST API 发送消息时，保存成功后通过 Hub 广播
 	messageService.SetBroadcaster(service.MessageBroadcasterFunc(func(msg *model.Message) {
@@ -134,18 +150,60 @@ func main() {
 
 	// 设置消息保存回调
 	// 当 WebSocket 收到聊天消息时，保存到数据库
-	wsHub.SetMessageSaver(func(ctx context.Context, msg *websocket.WSMessage) (*model.Message, error) {
+	wsHub.SetMessageSaver(func(ctx context.Context, msg *websocket.WSMessage) (*model.Message, bool, error) {
 		req := &service.SendMessageRequest{
-			ChatID:   msg.ChatID,
-			Type:     msg.MsgType,
-			Content:  msg.Content,
-			MediaURL: msg.MediaURL,
+			ChatID:      msg.ChatID,
+			Type:        msg.MsgType,
+			Content:     msg.Content,
+			MediaURL:    msg.MediaURL,
+			ReplyID:     msg.ReplyID,
+			ClientMsgID: msg.ClientMsgID,
+			TTL:         msg.TTL,
+			Poll:        msg.PollRequest(),
 		}
 		return messageService.SendMessageFromWS(ctx, msg.SenderID, req)
 	})
 
+	// 多实例部署时通过 Broker 把消息分发到持有连接的节点
+	if cfg.WebSocket.Broker == "redis" {
+		broker, err := websocket.NewRedisBroker(&cfg.Redis, cfg.WebSocket.Channel)
+		if err != nil {
+			logger.Fatal("Failed to create WebSocket broker", zap.Error(err))
+		}
+		defer broker.Close()
+		wsHub.SetBroker(broker, cfg.WebSocket.NodeID)
+	}
+
+	// 连接建立时订阅用户所在的全部聊天室，成员变更时同步订阅关系
+	wsHub.SetChatIDsLoader(chatRepo.GetChatIDsByUserID)
+
+	// 客户端携带 last_seq_id 重连时，复用增量同步补发错过的消息
+	wsHub.SetMessageReplayer(messageService.Sync)
+
+	// 客户端确认收到或读取消息时，经服务端校验记录状态后再通知发送者
+	wsHub.SetDeliveryAcker(messageService.AckDelivered)
+	wsHub.SetReadAcker(messageService.AckMessages)
+
+	// 消息编辑、删除、表情回应、成员加入等实时事件通过 Hub 推送
+	messageService.SetEventBroadcaster(wsHub)
+	chatService.SetEventBroadcaster(wsHub)
+	chatService.SetMembershipListener(wsHub)
+
+	// 修改聊天室信息、退出聊天室时在聊天室中发送服务消息
+	chatService.SetServiceMessageSender(messageService)
+	chatService.SetFileService(fileService)
+
 	go wsHub.Run()
 
+	// 定时发送到期的定时消息
+	go scheduledService.Run(context.Background(), 5*time.Second)
+
+	// 结束到期的投票并通知聊天室成员
+	go pollService.Run(context.Background(), 5*time.Second)
+
+	// 删除到期的自毁消息及其媒体文件
+	go messageSweeper.Run(context.Background(), 10*time.Second)
+
 	// Setup router
 	router := gin.Default()
 
@@ -207,14 +265,50 @@ func main() {
 		protected.POST("/chats", chatHandler.CreateChat)
 		protected.GET("/chats", chatHandler.GetUserChats)
 		protected.GET("/chats/:id", chatHandler.GetChat)
+		protected.PATCH("/chats/:id", chatHandler.UpdateChat)
+		protected.DELETE("/chats/:id", chatHandler.DeleteChat)
+		protected.POST("/chats/:id/leave", chatHandler.LeaveChat)
 		protected.POST("/chats/members", chatHandler.AddMember)
 		protected.DELETE("/chats/members", chatHandler.RemoveMember)
 		protected.GET("/chats/:id/members", chatHandler.GetMembers)
+		protected.PUT("/chats/:id/admins/:user_id", chatHandler.SetAdminRights)
+		protected.GET("/chats/:id/restrictions", chatHandler.GetRestrictions)
+		protected.PUT("/chats/:id/restrictions/:user_id", chatHandler.RestrictMember)
+		protected.DELETE("/chats/:id/restrictions/:user_id", chatHandler.LiftRestriction)
+		protected.POST("/chats/:id/subscribe", chatHandler.Subscribe)
+		protected.DELETE("/chats/:id/subscribe", chatHandler.Unsubscribe)
+		protected.PUT("/chats/:id/signatures", chatHandler.SetSignMessages)
+		protected.POST("/chats/:id/invites", inviteHandler.CreateInvite)
+		protected.GET("/chats/:id/invites", inviteHandler.GetInvites)
+		protected.DELETE("/chats/:id/invites/:invite_id", inviteHandler.RevokeInvite)
+		protected.POST("/chats/join/:code", inviteHandler.JoinByCode)
+		protected.GET("/chats/:id/join-requests", inviteHandler.GetJoinRequests)
+		protected.POST("/chats/:id/join-requests/:user_id/approve", inviteHandler.ApproveJoinRequest)
+		protected.POST("/chats/:id/join-requests/:user_id/deny", inviteHandler.DenyJoinRequest)
+		protected.PUT("/chats/:id/reactions", chatHandler.SetAvailableReactions)
+		protected.PUT("/chats/:id/ttl", chatHandler.SetMessageTTL)
 
 		// Message routes
 		protected.POST("/messages", messageHandler.SendMessage)
 		protected.GET("/messages", messageHandler.GetMessages)
+		protected.POST("/messages/forward", messageHandler.ForwardMessages)
+		protected.GET("/messages/search", searchHandler.SearchMessages)
+		protected.GET("/messages/scheduled", messageHandler.GetScheduledMessages)
+		protected.PATCH("/messages/scheduled/:id", messageHandler.UpdateScheduledMessage)
+		protected.DELETE("/messages/scheduled/:id", messageHandler.CancelScheduledMessage)
+		protected.PATCH("/messages/:id", messageHandler.EditMessage)
 		protected.DELETE("/messages/:id", messageHandler.DeleteMessage)
+		protected.GET("/messages/:id/edits", messageHandler.GetMessageEdits)
+		protected.GET("/messages/:id/read-by", messageHandler.GetReadBy)
+		protected.GET("/messages/:id/replies", messageHandler.GetReplies)
+		protected.POST("/messages/:id/pin", messageHandler.PinMessage)
+		protected.DELETE("/messages/:id/pin", messageHandler.UnpinMessage)
+		protected.POST("/messages/:id/reactions", messageHandler.SetReaction)
+		protected.DELETE("/messages/:id/reactions", messageHandler.RemoveReaction)
+		protected.POST("/messages/:id/poll/votes", messageHandler.VotePoll)
+		protected.DELETE("/messages/:id/poll/votes", messageHandler.RetractVote)
+		protected.POST("/messages/:id/poll/close", messageHandler.ClosePoll)
+		protected.GET("/messages/:id/poll/voters", messageHandler.GetPollVoters)
 		protected.POST("/messages/ack", messageHandler.AckMessage)
 
 		// Sync routes
@@ -7,14 +7,15 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Upload   UploadConfig   `yaml:"upload"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
-	JWT      JWTConfig      `yaml:"jwt"`
-	MinIO    MinIOConfig    `yaml:"minio"`
-	Log      LogConfig      `yaml:"log"`
+	Server    ServerConfig    `yaml:"server"`
+	Upload    UploadConfig    `yaml:"upload"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	WebSocket WebSocketConfig `yaml:"websocket"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	MinIO     MinIOConfig     `yaml:"minio"`
+	Log       LogConfig       `yaml:"log"`
 }
 
 type ServerConfig struct {
@@ -47,6 +48,13 @@ type RedisConfig struct {
 	DB       int    `yaml:"db"`
 }
 
+// WebSocketConfig WebSocket 跨节点分发配置
+type WebSocketConfig struct {
+	Broker  string `yaml:"broker"`  // 跨节点分发方式：为空表示单节点，redis 表示使用 Redis 发布订阅
+	NodeID  string `yaml:"node_id"` // 节点标识前缀，为空时使用 node
+	Channel string `yaml:"channel"` // Redis 发布订阅频道
+}
+
 type KafkaConfig struct {
 	Brokers []string `yaml:"brokers"`
 	Topic   string   `yaml:"topic"`
@@ -31,8 +31,19 @@ func NewDatabase(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	if err := db.AutoMigrate(
 		&model.User{},
 		&model.Message{},
+		&model.MessageEdit{},
+		&model.MessageDeletion{},
+		&model.MessageReaction{},
+		&model.ScheduledMessage{},
+		&model.Poll{},
+		&model.PollVote{},
 		&model.Chat{},
 		&model.ChatMember{},
+		&model.PinnedMessage{},
+		&model.ChatReadState{},
+		&model.ChatInvite{},
+		&model.ChatJoinRequest{},
+		&model.ChatRestriction{},
 		&model.UserSession{},
 		&model.Contact{},
 	); err != nil {
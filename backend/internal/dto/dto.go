@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type Response struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -42,25 +44,126 @@ type LoginRequest struct {
 //   - 3: 文件消息 (file) - 需要先上传文件，获取 MediaURL
 //   - 4: 语音消息 (voice) - 需要先上传音频，获取 MediaURL，可设置 Duration
 //   - 5: 位置消息 (location) - 需要设置 Latitude 和 Longitude
+//   - 6: 投票消息 (poll) - 需要设置 Poll，Content 会被设置为投票问题
 //
 // 发送媒体消息流程:
 //   1. 调用 POST /api/upload 上传文件，获取 URL
 //   2. 调用 POST /api/messages 发送消息，Type=2/3/4，MediaURL 填写上一步获取的 URL
+//
+// 设置 SendAt 时消息不会立即发送，到期后由服务端发送；到期前仅发送者可见，可以修改或取消
 type SendMessageRequest struct {
 	ChatID    int64   `json:"chat_id" form:"chat_id" binding:"required"`
-	Type      int     `json:"type" form:"type" binding:"required,min=1,max=5"`
+	Type      int     `json:"type" form:"type" binding:"required,min=1,max=6"`
 	Content   string  `json:"content" form:"content"`
 	MediaURL  string  `json:"media_url" form:"media_url"`
 	Duration  int     `json:"duration" form:"duration"`
 	Latitude  float64 `json:"latitude" form:"latitude"`
 	Longitude float64 `json:"longitude" form:"longitude"`
 	ReplyID   int64   `json:"reply_id" form:"reply_id"`
+	// ClientMsgID 客户端生成的随机ID（可选），超时重试时携带相同的值不会产生重复消息
+	ClientMsgID string `json:"client_msg_id" form:"client_msg_id" binding:"omitempty,max=64"`
+	// SendAt 定时发送时间（可选，RFC3339），必须晚于当前时间
+	SendAt *time.Time `json:"send_at" form:"send_at"`
+	// TTL 阅后即焚秒数（可选），从接收方首次已读开始计时，最长 7 天
+	TTL int `json:"ttl" form:"ttl" binding:"min=0,max=604800"`
+	// Poll 投票内容，Type=6 时必填；投票不能定时发送
+	Poll *PollRequest `json:"poll"`
+}
+
+// PollRequest 投票内容
+// 测验 (IsQuiz) 只能单选，CorrectOption 为正确选项的下标
+type PollRequest struct {
+	Question       string     `json:"question" binding:"required,max=300"`
+	Options        []string   `json:"options" binding:"required,min=2,max=10,dive,required,max=100"`
+	PublicVoters   bool       `json:"public_voters"`   // 是否公开投票人，默认匿名
+	MultipleChoice bool       `json:"multiple_choice"` // 是否可以多选
+	IsQuiz         bool       `json:"is_quiz"`
+	CorrectOption  int        `json:"correct_option" binding:"min=0"`
+	CloseAt        *time.Time `json:"close_at"` // 自动结束时间（可选，RFC3339）
+}
+
+// VotePollRequest 投票请求，单选投票只能选一个
+type VotePollRequest struct {
+	Options []int `json:"options" binding:"required,min=1,max=10,dive,min=0"`
+}
+
+// GetPollVotersRequest 获取选择某个选项的用户
+type GetPollVotersRequest struct {
+	Option int `json:"option" form:"option" binding:"min=0"`
+	Offset int `json:"offset" form:"offset" binding:"min=0"`
+	Limit  int `json:"limit" form:"limit,default=50" binding:"min=1,max=100"`
+}
+
+// GetScheduledMessagesRequest 获取定时消息请求，ChatID 为空时返回所有聊天室的
+type GetScheduledMessagesRequest struct {
+	ChatID int64 `json:"chat_id" form:"chat_id"`
+}
+
+// UpdateScheduledMessageRequest 修改定时消息请求，为空的字段不修改；新内容按发送消息的规则校验
+type UpdateScheduledMessageRequest struct {
+	Content *string    `json:"content"`
+	SendAt  *time.Time `json:"send_at"`
+}
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	Content string `json:"content" form:"content" binding:"required"`
+}
+
+// DeleteMessageRequest 删除消息请求
+// ForMe 为 true 时仅对自己删除，否则对所有人删除
+type DeleteMessageRequest struct {
+	ForMe bool `json:"for_me" form:"for_me"`
+}
+
+// ReactionRequest 表情回应请求
+type ReactionRequest struct {
+	Emoji string `json:"emoji" form:"emoji" binding:"required,max=32"`
+}
+
+// SetAvailableReactionsRequest 设置聊天室允许使用的表情回应，为空表示不限制
+type SetAvailableReactionsRequest struct {
+	Reactions []string `json:"reactions" binding:"dive,required,max=32"`
 }
 
+// ForwardMessagesRequest 转发消息请求，一次最多转发 100 条
+type ForwardMessagesRequest struct {
+	TargetChatID int64   `json:"target_chat_id" binding:"required"`
+	MessageIDs   []int64 `json:"message_ids" binding:"required,min=1,max=100,unique"`
+}
+
+// SetMessageTTLRequest 设置聊天室自动删除时间，0 表示关闭，最长 1 年
+type SetMessageTTLRequest struct {
+	TTL int `json:"ttl" binding:"min=0,max=31536000"`
+}
+
+// GetMessagesRequest 获取聊天记录请求，按 SeqID 游标分页
+// before_seq_id、after_seq_id、around_message_id 最多指定一个，都不指定时返回最新的消息
 type GetMessagesRequest struct {
-	ChatID int64 `json:"chat_id" form:"chat_id" binding:"required"`
-	Offset  int   `json:"offset" form:"offset"`
-	Limit   int   `json:"limit" form:"limit,default=50"`
+	ChatID          int64 `json:"chat_id" form:"chat_id" binding:"required"`
+	BeforeSeqID     int64 `json:"before_seq_id" form:"before_seq_id"`
+	AfterSeqID      int64 `json:"after_seq_id" form:"after_seq_id"`
+	AroundMessageID int64 `json:"around_message_id" form:"around_message_id"`
+	Limit           int   `json:"limit" form:"limit,default=50" binding:"min=1,max=100"`
+}
+
+// SearchMessagesRequest 搜索消息请求
+// 只搜索当前用户所在的聊天室；From/To 为 RFC3339 时间，按发送时间过滤 [from, to)
+type SearchMessagesRequest struct {
+	Query    string    `json:"q" form:"q" binding:"required,max=100"`
+	ChatID   int64     `json:"chat_id" form:"chat_id"`
+	SenderID int64     `json:"sender_id" form:"sender_id"`
+	Type     int       `json:"type" form:"type" binding:"omitempty,min=1,max=6"`
+	From     time.Time `json:"from" form:"from"`
+	To       time.Time `json:"to" form:"to"`
+	Offset   int       `json:"offset" form:"offset" binding:"min=0"`
+	Limit    int       `json:"limit" form:"limit,default=20" binding:"min=1,max=50"`
+}
+
+// GetRepliesRequest 获取回复列表请求
+type GetRepliesRequest struct {
+	Offset int `json:"offset" form:"offset"`
+	Limit  int `json:"limit" form:"limit,default=50" binding:"max=100"`
 }
 
 type CreateChatRequest struct {
@@ -72,3 +175,37 @@ type AddChatMemberRequest struct {
 	ChatID int64 `json:"chat_id" form:"chat_id" binding:"required"`
 	UserID int64 `json:"user_id" form:"user_id" binding:"required"`
 }
+
+// UpdateChatRequest 修改聊天室信息请求，未传的字段不修改
+// avatar 为 /api/upload 返回的图片URL，空字符串表示移除头像
+type UpdateChatRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Avatar      *string `json:"avatar" binding:"omitempty,max=500"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+}
+
+// SetAdminRightsRequest 设置管理员权限请求，权限按位组合，0 表示撤销管理员
+// 1: 邀请成员, 2: 移除成员, 4: 置顶消息, 8: 修改设置, 16: 删除消息, 32: 编辑频道消息, 64: 管理管理员, 128: 在频道中发布消息
+type SetAdminRightsRequest struct {
+	Rights int64 `json:"rights" binding:"min=0,max=255"`
+}
+
+// SetSignMessagesRequest 设置频道消息是否显示发布者签名请求
+type SetSignMessagesRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// RestrictMemberRequest 限制成员请求，muted_until 为禁言到期时间，至少需要设置一项限制
+type RestrictMemberRequest struct {
+	Banned     bool       `json:"banned"`
+	MutedUntil *time.Time `json:"muted_until"`
+	NoMedia    bool       `json:"no_media"`
+}
+
+// CreateInviteRequest 创建邀请链接请求，max_uses 为 0 表示不限次数，需要批准的链接不能限制次数
+type CreateInviteRequest struct {
+	Title            string     `json:"title" binding:"max=32"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	MaxUses          int        `json:"max_uses" binding:"min=0"`
+	RequiresApproval bool       `json:"requires_approval"`
+}
@@ -53,7 +53,7 @@ func (h *ChatHandler) CreateChat(c *gin.Context) {
 }
 
 // @Summary Get a chat
-// @Description Get chat details by ID
+// @Description Get chat details by ID, including pinned messages for members
 // @Tags chats
 // @Produce json
 // @Security BearerAuth
@@ -69,7 +69,14 @@ func (h *ChatHandler) GetChat(c *gin.Context) {
 		return
 	}
 
-	chat, err := h.chatService.GetChat(c.Request.Context(), req.ChatID)
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	chat, err := h.chatService.GetChat(c.Request.Context(), req.ChatID, currentUser.UserID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, dto.Error(404, "chat not found"))
 		return
@@ -78,6 +85,118 @@ func (h *ChatHandler) GetChat(c *gin.Context) {
 	c.JSON(http.StatusOK, dto.Success(chat))
 }
 
+// @Summary Update chat info
+// @Description Change the name, avatar or description of a group or channel. Each change posts a service message into the chat. Requires the edit info right
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param request body dto.UpdateChatRequest true "Chat info"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id} [patch]
+func (h *ChatHandler) UpdateChat(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.UpdateChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	chat, err := h.chatService.UpdateChatInfo(c.Request.Context(), uri.ChatID, currentUser.UserID, &service.UpdateChatInfoRequest{
+		Name:        req.Name,
+		Avatar:      req.Avatar,
+		Description: req.Description,
+	})
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(chat))
+}
+
+// @Summary Delete chat
+// @Description Delete a chat and remove all of its members. Only the owner can delete a chat
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id} [delete]
+func (h *ChatHandler) DeleteChat(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.chatService.DeleteChat(c.Request.Context(), req.ChatID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Leave chat
+// @Description Leave a group or channel. When the owner leaves, ownership passes to the earliest admin, or the earliest member if there are no admins; the chat is deleted when its last member leaves
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/leave [post]
+func (h *ChatHandler) LeaveChat(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.chatService.LeaveChat(c.Request.Context(), req.ChatID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
 // @Summary Get user chats
 // @Description Get all chats for current user
 // @Tags chats
@@ -103,7 +222,7 @@ func (h *ChatHandler) GetUserChats(c *gin.Context) {
 }
 
 // @Summary Add member to chat
-// @Description Add a user to a chat
+// @Description Add a user to a group or channel. Requires the invite right. The creator of a private chat can add the other person while the chat has a single member
 // @Tags chats
 // @Accept json
 // @Produce json
@@ -118,14 +237,16 @@ func (h *ChatHandler) AddMember(c *gin.Context) {
 		return
 	}
 
-	err := h.chatService.AddMember(c.Request.Context(), req.ChatID, req.UserID, 1)
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	err := h.chatService.AddMember(c.Request.Context(), req.ChatID, currentUser.UserID, req.UserID)
 	if err != nil {
-		code := 500
-		message := err.Error()
-		if err == service.ErrUserNotFound {
-			code = 404
-			message = "User not found"
-		}
+		code, message := service.ErrorCode(err)
 		c.JSON(code, dto.Error(code, message))
 		return
 	}
@@ -134,7 +255,7 @@ func (h *ChatHandler) AddMember(c *gin.Context) {
 }
 
 // @Summary Remove member from chat
-// @Description Remove a user from a chat
+// @Description Remove a user from a chat. Requires the kick right and a higher role than the removed member. Members can remove themselves to leave
 // @Tags chats
 // @Accept json
 // @Produce json
@@ -152,17 +273,109 @@ func (h *ChatHandler) RemoveMember(c *gin.Context) {
 		return
 	}
 
-	err := h.chatService.RemoveMember(c.Request.Context(), req.ChatID, req.UserID)
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	err := h.chatService.RemoveMember(c.Request.Context(), req.ChatID, currentUser.UserID, req.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.Error(500, err.Error()))
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
 		return
 	}
 
 	c.JSON(http.StatusOK, dto.Success(nil))
 }
 
+// @Summary Set available reactions
+// @Description Restrict which emoji reactions can be used in a chat. An empty list allows all reactions
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param request body dto.SetAvailableReactionsRequest true "Available reactions"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/reactions [put]
+func (h *ChatHandler) SetAvailableReactions(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.SetAvailableReactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	chat, err := h.chatService.SetAvailableReactions(c.Request.Context(), uri.ChatID, currentUser.UserID, req.Reactions)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(chat))
+}
+
+// @Summary Set auto-delete timer
+// @Description Set how many seconds after sending new messages in the chat are deleted. 0 turns it off
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param request body dto.SetMessageTTLRequest true "Auto-delete timer"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/ttl [put]
+func (h *ChatHandler) SetMessageTTL(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.SetMessageTTLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	chat, err := h.chatService.SetMessageTTL(c.Request.Context(), uri.ChatID, currentUser.UserID, req.TTL)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(chat))
+}
+
 // @Summary Get chat members
-// @Description Get all members of a chat
+// @Description Get all members of a chat. Only members can see the list, and channel members are only visible to admins
 // @Tags chats
 // @Produce json
 // @Security BearerAuth
@@ -178,11 +391,288 @@ func (h *ChatHandler) GetMembers(c *gin.Context) {
 		return
 	}
 
-	members, err := h.chatService.GetMembers(c.Request.Context(), req.ChatID)
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	members, err := h.chatService.GetMembers(c.Request.Context(), req.ChatID, currentUser.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.Error(500, err.Error()))
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
 		return
 	}
 
 	c.JSON(http.StatusOK, dto.Success(members))
 }
+
+// @Summary Set admin rights
+// @Description Promote a member to admin, change an admin's rights, or demote with rights 0. Requires the manage admins right and only rights the caller holds can be granted
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param user_id path int true "User ID"
+// @Param request body dto.SetAdminRightsRequest true "Admin rights"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/admins/{user_id} [put]
+func (h *ChatHandler) SetAdminRights(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+		UserID int64 `uri:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.SetAdminRightsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	member, err := h.chatService.SetAdminRights(c.Request.Context(), uri.ChatID, currentUser.UserID, uri.UserID, service.Right(req.Rights))
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(member))
+}
+
+// @Summary Restrict a member
+// @Description Ban a user, mute a member until a timestamp, or stop them sending media. Replaces any existing restriction. Banned members are removed and cannot rejoin. Requires the kick right
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param user_id path int true "User ID"
+// @Param request body dto.RestrictMemberRequest true "Restriction"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/restrictions/{user_id} [put]
+func (h *ChatHandler) RestrictMember(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+		UserID int64 `uri:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.RestrictMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	restriction, err := h.chatService.RestrictMember(c.Request.Context(), uri.ChatID, currentUser.UserID, uri.UserID, &service.RestrictRequest{
+		Banned:     req.Banned,
+		MutedUntil: req.MutedUntil,
+		NoMedia:    req.NoMedia,
+	})
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(restriction))
+}
+
+// @Summary Lift restrictions
+// @Description Remove every restriction of a user, allowing banned users to join again. Requires the kick right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param user_id path int true "User ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/restrictions/{user_id} [delete]
+func (h *ChatHandler) LiftRestriction(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+		UserID int64 `uri:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.chatService.LiftRestriction(c.Request.Context(), req.ChatID, currentUser.UserID, req.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Get restrictions
+// @Description Get active bans, mutes and media restrictions of a chat. Requires the kick right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/restrictions [get]
+func (h *ChatHandler) GetRestrictions(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	restrictions, err := h.chatService.GetRestrictions(c.Request.Context(), req.ChatID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(restrictions))
+}
+
+// @Summary Subscribe to a channel
+// @Description Join a channel as a subscriber. Subscribers can read but not post
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/subscribe [post]
+func (h *ChatHandler) Subscribe(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.chatService.Subscribe(c.Request.Context(), uri.ChatID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Unsubscribe from a channel
+// @Description Leave a channel. The owner cannot unsubscribe
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/subscribe [delete]
+func (h *ChatHandler) Unsubscribe(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.chatService.Unsubscribe(c.Request.Context(), uri.ChatID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Set channel signatures
+// @Description Sign new channel posts with the name of the admin who posted them
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param request body dto.SetSignMessagesRequest true "Signatures"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/signatures [put]
+func (h *ChatHandler) SetSignMessages(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.SetSignMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	chat, err := h.chatService.SetSignMessages(c.Request.Context(), uri.ChatID, currentUser.UserID, req.Enabled)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(chat))
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/forever-free1/telegram-go/backend/internal/dto"
+	"github.com/forever-free1/telegram-go/backend/internal/service"
+)
+
+type InviteHandler struct {
+	inviteService *service.InviteService
+}
+
+func NewInviteHandler(inviteService *service.InviteService) *InviteHandler {
+	return &InviteHandler{inviteService: inviteService}
+}
+
+// @Summary Create invite link
+// @Description Create an invite link with optional expiry, usage limit or approval requirement. Requires the invite right
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param request body dto.CreateInviteRequest true "Invite link settings"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/invites [post]
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	var uri struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	invite, err := h.inviteService.CreateInvite(c.Request.Context(), uri.ChatID, currentUser.UserID, &service.CreateInviteRequest{
+		Title:            req.Title,
+		ExpiresAt:        req.ExpiresAt,
+		MaxUses:          req.MaxUses,
+		RequiresApproval: req.RequiresApproval,
+	})
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(invite))
+}
+
+// @Summary Get invite links
+// @Description Get all invite links of a chat, including revoked and expired ones. Requires the invite right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/invites [get]
+func (h *InviteHandler) GetInvites(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	invites, err := h.inviteService.GetInvites(c.Request.Context(), req.ChatID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(invites))
+}
+
+// @Summary Revoke invite link
+// @Description Revoke an invite link so it can no longer be used. Requires the invite right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param invite_id path int true "Invite link ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/invites/{invite_id} [delete]
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	var req struct {
+		ChatID   int64 `uri:"id" binding:"required"`
+		InviteID int64 `uri:"invite_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	invite, err := h.inviteService.RevokeInvite(c.Request.Context(), req.ChatID, req.InviteID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(invite))
+}
+
+// @Summary Join chat by invite link
+// @Description Join a chat with an invite code. Links requiring approval create a pending join request instead
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "Invite code"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/join/{code} [post]
+func (h *InviteHandler) JoinByCode(c *gin.Context) {
+	var req struct {
+		Code string `uri:"code" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	result, err := h.inviteService.JoinByCode(c.Request.Context(), currentUser.UserID, req.Code)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(result))
+}
+
+// @Summary Get join requests
+// @Description Get pending join requests of a chat. Requires the invite right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/join-requests [get]
+func (h *InviteHandler) GetJoinRequests(c *gin.Context) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	requests, err := h.inviteService.GetJoinRequests(c.Request.Context(), req.ChatID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(requests))
+}
+
+// @Summary Approve join request
+// @Description Approve a pending join request and add the user to the chat. Requires the invite right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param user_id path int true "Requesting user ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/join-requests/{user_id}/approve [post]
+func (h *InviteHandler) ApproveJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, true)
+}
+
+// @Summary Deny join request
+// @Description Deny a pending join request. Requires the invite right
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Chat ID"
+// @Param user_id path int true "Requesting user ID"
+// @Success 200 {object} dto.Response
+// @Router /api/chats/{id}/join-requests/{user_id}/deny [post]
+func (h *InviteHandler) DenyJoinRequest(c *gin.Context) {
+	h.decideJoinRequest(c, false)
+}
+
+func (h *InviteHandler) decideJoinRequest(c *gin.Context, approved bool) {
+	var req struct {
+		ChatID int64 `uri:"id" binding:"required"`
+		UserID int64 `uri:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	var err error
+	if approved {
+		err = h.inviteService.ApproveJoinRequest(c.Request.Context(), req.ChatID, currentUser.UserID, req.UserID)
+	} else {
+		err = h.inviteService.DenyJoinRequest(c.Request.Context(), req.ChatID, currentUser.UserID, req.UserID)
+	}
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -11,16 +12,18 @@ import (
 )
 
 type MessageHandler struct {
-	messageService *service.MessageService
-	hub           *websocket.Hub
+	messageService   *service.MessageService
+	scheduledService *service.ScheduledMessageService
+	pollService      *service.PollService
+	hub              *websocket.Hub
 }
 
-func NewMessageHandler(messageService *service.MessageService, hub *websocket.Hub) *MessageHandler {
-	return &MessageHandler{messageService: messageService, hub: hub}
+func NewMessageHandler(messageService *service.MessageService, scheduledService *service.ScheduledMessageService, pollService *service.PollService, hub *websocket.Hub) *MessageHandler {
+	return &MessageHandler{messageService: messageService, scheduledService: scheduledService, pollService: pollService, hub: hub}
 }
 
 // @Summary Send a message
-// @Description Send a message to a chat
+// @Description Send a message to a chat. With send_at the message is scheduled and a scheduled message is returned
 // @Tags messages
 // @Accept json
 // @Produce json
@@ -42,27 +45,158 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	}
 
 	currentUser := user.(*service.UserClaims)
-	message, err := h.messageService.SendMessage(c.Request.Context(), currentUser.UserID, &service.SendMessageRequest{
-		ChatID:    req.ChatID,
-		Type:      req.Type,
-		Content:   req.Content,
-		MediaURL:  req.MediaURL,
-		Duration:  req.Duration,
-		Latitude:  req.Latitude,
-		Longitude: req.Longitude,
-		ReplyID:   req.ReplyID,
+	sendReq := &service.SendMessageRequest{
+		ChatID:      req.ChatID,
+		Type:        req.Type,
+		Content:     req.Content,
+		MediaURL:    req.MediaURL,
+		Duration:    req.Duration,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		ReplyID:     req.ReplyID,
+		ClientMsgID: req.ClientMsgID,
+		TTL:         req.TTL,
+	}
+	if req.Poll != nil {
+		sendReq.Poll = &service.PollRequest{
+			Question:       req.Poll.Question,
+			Options:        req.Poll.Options,
+			PublicVoters:   req.Poll.PublicVoters,
+			MultipleChoice: req.Poll.MultipleChoice,
+			IsQuiz:         req.Poll.IsQuiz,
+			CorrectOption:  req.Poll.CorrectOption,
+			CloseAt:        req.Poll.CloseAt,
+		}
+	}
+
+	// 定时消息
+	if req.SendAt != nil {
+		scheduled, err := h.scheduledService.Schedule(c.Request.Context(), currentUser.UserID, sendReq, *req.SendAt)
+		if err != nil {
+			code, message := service.ErrorCode(err)
+			c.JSON(code, dto.Error(code, message))
+			return
+		}
+		c.JSON(http.StatusOK, dto.Success(scheduled))
+		return
+	}
+
+	message, err := h.messageService.SendMessage(c.Request.Context(), currentUser.UserID, sendReq)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(message))
+}
+
+// @Summary Forward messages
+// @Description Forward messages into another chat, keeping the original sender and chat
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.ForwardMessagesRequest true "Forward request"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/forward [post]
+func (h *MessageHandler) ForwardMessages(c *gin.Context) {
+	var req dto.ForwardMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	messages, err := h.messageService.ForwardMessages(c.Request.Context(), currentUser.UserID, &service.ForwardMessagesRequest{
+		TargetChatID: req.TargetChatID,
+		MessageIDs:   req.MessageIDs,
 	})
 	if err != nil {
-		code := 500
-		message := err.Error()
-		switch err {
-		case service.ErrChatNotFound:
-			code = 404
-			message = "Chat not found"
-		case service.ErrNotAuthorized:
-			code = 403
-			message = "Not authorized"
-		}
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(messages))
+}
+
+// @Summary Get scheduled messages
+// @Description Get the current user's pending scheduled messages
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param chat_id query int false "Chat ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/scheduled [get]
+func (h *MessageHandler) GetScheduledMessages(c *gin.Context) {
+	var req dto.GetScheduledMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	messages, err := h.scheduledService.GetPending(c.Request.Context(), currentUser.UserID, req.ChatID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(messages))
+}
+
+// @Summary Update a scheduled message
+// @Description Change the content or send time of a pending scheduled message
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Scheduled message ID"
+// @Param request body dto.UpdateScheduledMessageRequest true "Update request"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/scheduled/{id} [patch]
+func (h *MessageHandler) UpdateScheduledMessage(c *gin.Context) {
+	var uri struct {
+		ID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.UpdateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	message, err := h.scheduledService.Update(c.Request.Context(), uri.ID, currentUser.UserID, &service.UpdateScheduledRequest{
+		Content: req.Content,
+		SendAt:  req.SendAt,
+	})
+	if err != nil {
+		code, message := service.ErrorCode(err)
 		c.JSON(code, dto.Error(code, message))
 		return
 	}
@@ -70,13 +204,48 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, dto.Success(message))
 }
 
+// @Summary Cancel a scheduled message
+// @Description Cancel a pending scheduled message
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Scheduled message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/scheduled/{id} [delete]
+func (h *MessageHandler) CancelScheduledMessage(c *gin.Context) {
+	var req struct {
+		ID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.scheduledService.Cancel(c.Request.Context(), req.ID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
 // @Summary Get messages
-// @Description Get messages from a chat
+// @Description Get a page of chat history, newest first. Use next_cursor as before_seq_id to load older messages and prev_cursor as after_seq_id to load newer ones
 // @Tags messages
 // @Produce json
 // @Security BearerAuth
 // @Param chat_id query int true "Chat ID"
-// @Param offset query int false "Offset"
+// @Param before_seq_id query int false "Messages older than this seq_id"
+// @Param after_seq_id query int false "Messages newer than this seq_id"
+// @Param around_message_id query int false "The message and its neighbours"
 // @Param limit query int false "Limit"
 // @Success 200 {object} dto.Response
 // @Router /api/messages [get]
@@ -98,31 +267,29 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 	}
 
 	currentUser := user.(*service.UserClaims)
-	messages, err := h.messageService.GetMessages(c.Request.Context(), req.ChatID, currentUser.UserID, req.Offset, req.Limit)
+	page, err := h.messageService.GetMessages(c.Request.Context(), currentUser.UserID, &service.GetMessagesRequest{
+		ChatID:          req.ChatID,
+		BeforeSeqID:     req.BeforeSeqID,
+		AfterSeqID:      req.AfterSeqID,
+		AroundMessageID: req.AroundMessageID,
+		Limit:           req.Limit,
+	})
 	if err != nil {
-		code := 500
-		message := err.Error()
-		switch err {
-		case service.ErrChatNotFound:
-			code = 404
-			message = "Chat not found"
-		case service.ErrNotAuthorized:
-			code = 403
-			message = "Not authorized"
-		}
+		code, message := service.ErrorCode(err)
 		c.JSON(code, dto.Error(code, message))
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.Success(messages))
+	c.JSON(http.StatusOK, dto.Success(page))
 }
 
 // @Summary Delete a message
-// @Description Delete a message (soft delete)
+// @Description Delete a message for everyone (sender, or group/channel admins), or only for the current user with for_me=true
 // @Tags messages
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Message ID"
+// @Param for_me query bool false "Only delete for the current user"
 // @Success 200 {object} dto.Response
 // @Router /api/messages/{id} [delete]
 func (h *MessageHandler) DeleteMessage(c *gin.Context) {
@@ -134,6 +301,12 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 		return
 	}
 
+	var query dto.DeleteMessageRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
@@ -141,18 +314,118 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	}
 
 	currentUser := user.(*service.UserClaims)
-	err := h.messageService.DeleteMessage(c.Request.Context(), req.MessageID, currentUser.UserID)
+	err := h.messageService.DeleteMessage(c.Request.Context(), req.MessageID, currentUser.UserID, query.ForMe)
 	if err != nil {
-		code := 500
-		message := err.Error()
-		switch err {
-		case service.ErrMessageNotFound:
-			code = 404
-			message = "Message not found"
-		case service.ErrNotAuthorized:
-			code = 403
-			message = "Not authorized"
-		}
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Edit a message
+// @Description Edit the content of a message. Only the sender (or channel admins) can edit
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Param request body dto.EditMessageRequest true "Edit request"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id} [patch]
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	message, err := h.messageService.EditMessage(c.Request.Context(), uri.MessageID, currentUser.UserID, req.Content)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(message))
+}
+
+// @Summary Get message edit history
+// @Description Get the previous versions of an edited message
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/edits [get]
+func (h *MessageHandler) GetMessageEdits(c *gin.Context) {
+	var req struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	edits, err := h.messageService.GetMessageEdits(c.Request.Context(), req.MessageID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(edits))
+}
+
+// @Summary Pin a message
+// @Description Pin a message in its chat. Groups and channels require admin or owner
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/pin [post]
+func (h *MessageHandler) PinMessage(c *gin.Context) {
+	var req struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.messageService.PinMessage(c.Request.Context(), req.MessageID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
 		c.JSON(code, dto.Error(code, message))
 		return
 	}
@@ -160,6 +433,161 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, dto.Success(nil))
 }
 
+// @Summary Unpin a message
+// @Description Unpin a message. Groups and channels require admin or owner
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/pin [delete]
+func (h *MessageHandler) UnpinMessage(c *gin.Context) {
+	var req struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	if err := h.messageService.UnpinMessage(c.Request.Context(), req.MessageID, currentUser.UserID); err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(nil))
+}
+
+// @Summary Get replies
+// @Description Page through the replies to a message. The parent message carries the reply count
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/replies [get]
+func (h *MessageHandler) GetReplies(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.GetRepliesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	page, err := h.messageService.GetReplies(c.Request.Context(), uri.MessageID, currentUser.UserID, req.Offset, req.Limit)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(page))
+}
+
+// @Summary React to a message
+// @Description Add an emoji reaction to a message, replacing the user's previous reaction
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Param request body dto.ReactionRequest true "Reaction request"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/reactions [post]
+func (h *MessageHandler) SetReaction(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	reactions, err := h.messageService.SetReaction(c.Request.Context(), uri.MessageID, currentUser.UserID, req.Emoji)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(reactions))
+}
+
+// @Summary Remove a reaction
+// @Description Remove the current user's reaction from a message
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/reactions [delete]
+func (h *MessageHandler) RemoveReaction(c *gin.Context) {
+	var req struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	reactions, err := h.messageService.RemoveReaction(c.Request.Context(), req.MessageID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(reactions))
+}
+
 // AckMessageRequest 消息已读确认请求
 type AckMessageRequest struct {
 	MessageIDs []int64 `json:"message_ids" binding:"required,min=1"`
@@ -195,24 +623,16 @@ func (h *MessageHandler) AckMessage(c *gin.Context) {
 	// 调用 Service 处理已读确认
 	readMessages, err := h.messageService.AckMessages(c.Request.Context(), currentUser.UserID, req.ChatID, req.MessageIDs)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.Error(500, err.Error()))
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
 		return
 	}
 
 	// 通过 WebSocket 通知消息发送者
-	if h.hub != nil && len(readMessages) > 0 {
-		for _, msg := range readMessages {
-			// 通知消息发送者其消息已被读
-			readReceipt := &websocket.WSMessage{
-				Type:      "WS_MSG_READ",
-				MessageID: msg.ID,
-				ChatID:    msg.ChatID,
-				SenderID:  currentUser.UserID, // 已读者
-			}
-			if msg.ReadAt != nil {
-				readReceipt.Timestamp = *msg.ReadAt
-			}
-			h.hub.SendToUser(msg.SenderID, readReceipt)
+	if h.hub != nil {
+		readAt := time.Now()
+		for _, message := range readMessages {
+			h.hub.SendReceipt(websocket.WSMsgReadType, message, currentUser.UserID, readAt)
 		}
 	}
 
@@ -229,7 +649,7 @@ type SyncRequest struct {
 // Sync 增量同步 - 获取lastSeqID之后的所有消息
 // GET /api/sync?last_seq_id=xxx
 // @Summary Sync messages
-// @Description Get incremental updates since last_seq_id
+// @Description Get incremental updates since last_seq_id. Messages up to last_seq_id are marked delivered and their senders receive WS_MSG_DELIVERED
 // @Tags sync
 // @Produce json
 // @Security BearerAuth
@@ -237,7 +657,7 @@ type SyncRequest struct {
 // @Success 200 {object} dto.Response
 // @Router /api/sync [get]
 func (h *MessageHandler) Sync(c *gin.Context) {
-	var req service.SyncRequest
+	var req SyncRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.Error(400, "invalid or missing last_seq_id"))
 		return
@@ -258,5 +678,201 @@ func (h *MessageHandler) Sync(c *gin.Context) {
 		return
 	}
 
+	// last_seq_id 之前的消息设备都已收到，记录送达并通知发送者
+	// 送达状态记录失败不影响同步结果，错误已由 Service 记录
+	delivered, err := h.messageService.AckDeliveredUpTo(c.Request.Context(), currentUser.UserID, req.LastSeqID)
+	if err == nil && h.hub != nil {
+		for _, message := range delivered {
+			h.hub.SendReceipt(websocket.WSMsgDeliveredType, message, currentUser.UserID, *message.DeliveredAt)
+		}
+	}
+
 	c.JSON(http.StatusOK, dto.Success(syncResult))
 }
+
+// @Summary Vote in a poll
+// @Description Vote in a poll message, replacing the user's previous vote. Quiz answers cannot be changed
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Param request body dto.VotePollRequest true "Chosen options"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/poll/votes [post]
+func (h *MessageHandler) VotePoll(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.VotePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	poll, err := h.pollService.Vote(c.Request.Context(), uri.MessageID, currentUser.UserID, req.Options)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(poll))
+}
+
+// @Summary Retract a poll vote
+// @Description Retract the current user's vote. Not available for quizzes
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/poll/votes [delete]
+func (h *MessageHandler) RetractVote(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	poll, err := h.pollService.RetractVote(c.Request.Context(), uri.MessageID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(poll))
+}
+
+// @Summary Close a poll
+// @Description Stop a poll early. Only the user who sent the poll can close it
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/poll/close [post]
+func (h *MessageHandler) ClosePoll(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	poll, err := h.pollService.ClosePoll(c.Request.Context(), uri.MessageID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(poll))
+}
+
+// @Summary Get poll voters
+// @Description Get users who chose an option. Only available for polls with public voters
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Param option query int true "Option index"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/poll/voters [get]
+func (h *MessageHandler) GetPollVoters(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	var req dto.GetPollVotersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	voters, err := h.pollService.GetVoters(c.Request.Context(), uri.MessageID, currentUser.UserID, req.Option, req.Offset, req.Limit)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(voters))
+}
+
+// @Summary Get read receipts
+// @Description Get members who have read a message. Only the sender of the message can see this
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Message ID"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/{id}/read-by [get]
+func (h *MessageHandler) GetReadBy(c *gin.Context) {
+	var uri struct {
+		MessageID int64 `uri:"id" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	readers, err := h.messageService.GetReadBy(c.Request.Context(), uri.MessageID, currentUser.UserID)
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(readers))
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/service"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+	"github.com/forever-free1/telegram-go/backend/internal/websocket"
+	"github.com/forever-free1/telegram-go/backend/pkg/snowflake"
+)
+
+const testUserID = 1
+
+// newTestRouter 创建使用假数据库的消息接口，请求以 testUserID 的身份发出；hub 可以为空
+func newTestRouter(t *testing.T, hub *websocket.Hub) (*testutil.FakeDB, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	_, err := snowflake.NewSnowflake(1)
+	require.NoError(t, err)
+	fake, db := testutil.NewFakeDB(t)
+
+	logger := zap.NewNop()
+	messageService := service.NewMessageService(
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		repository.NewPollRepository(db),
+		repository.NewUserRepository(db),
+		logger,
+	)
+	h := NewMessageHandler(messageService, nil, nil, hub)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: testUserID})
+	})
+	r.GET("/api/sync", h.Sync)
+	r.PATCH("/api/messages/:id", h.EditMessage)
+	r.POST("/api/messages/ack", h.AckMessage)
+	r.GET("/api/messages/:id/poll/voters", h.GetPollVoters)
+	return fake, r
+}
+
+// syncResult 发起同步请求并解析响应
+func syncResult(t *testing.T, r *gin.Engine, query string) *service.SyncResponse {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sync?"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Data service.SyncResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return &resp.Data
+}
+
+func TestSync_PassesLastSeqIDFromQuery(t *testing.T) {
+	fake, r := newTestRouter(t, websocket.NewHub())
+	fake.On("SELECT `chat_id` FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"chat_id"},
+		Values:  [][]driver.Value{{int64(7)}},
+	})
+
+	result := syncResult(t, r, "last_seq_id=42")
+	assert.Equal(t, int64(42), result.SeqID)
+
+	// 同步时 last_seq_id 之前的消息记录为已送达
+	undelivered := fake.Executed("delivered_at IS NULL")
+	require.Len(t, undelivered, 1)
+	assert.Contains(t, undelivered[0].Args, driver.Value(int64(42)))
+}
+
+// messageColumns 假数据库中消息行的列
+var messageColumns = []string{"id", "chat_id", "sender_id", "type", "content", "seq_id", "update_seq_id", "is_deleted"}
+
+func TestSync_ReturnsEditsMadeAfterLastSync(t *testing.T) {
+	fake, r := newTestRouter(t, websocket.NewHub())
+	fake.On("SELECT `chat_id` FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"chat_id"},
+		Values:  [][]driver.Value{{int64(7)}},
+	})
+	fake.On("WHERE id = ?", &testutil.Rows{
+		Columns: messageColumns,
+		Values:  [][]driver.Value{{int64(5), int64(7), int64(testUserID), int64(1), "hello", int64(40), int64(0), false}},
+	})
+
+	// 客户端已经同步到 41，之后消息被编辑
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/messages/5", strings.NewReader(`{"content":"hello, world"}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var edited struct {
+		Data struct {
+			UpdateSeqID int64 `json:"update_seq_id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &edited))
+	require.Greater(t, edited.Data.UpdateSeqID, int64(41))
+
+	fake.On("update_seq_id > ?", &testutil.Rows{
+		Columns: messageColumns,
+		Values:  [][]driver.Value{{int64(5), int64(7), int64(testUserID), int64(1), "hello, world", int64(40), edited.Data.UpdateSeqID, false}},
+	})
+
+	result := syncResult(t, r, "last_seq_id=41")
+	require.Len(t, result.Edits, 1)
+	assert.Equal(t, "hello, world", result.Edits[0].Content)
+	assert.Empty(t, result.Deleted)
+	assert.Equal(t, edited.Data.UpdateSeqID, result.SeqID)
+
+	updates := fake.Executed("update_seq_id > ?")
+	require.Len(t, updates, 1)
+	assert.Contains(t, updates[0].Args, driver.Value(int64(41)))
+}
+
+func TestSync_ReturnsTombstonesForDeletionsAfterLastSync(t *testing.T) {
+	fake, r := newTestRouter(t, websocket.NewHub())
+	fake.On("SELECT `chat_id` FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"chat_id"},
+		Values:  [][]driver.Value{{int64(7)}},
+	})
+	// 消息 5 在同步后被所有人删除，消息 6 只对当前用户删除
+	fake.On("update_seq_id > ?", &testutil.Rows{
+		Columns: messageColumns,
+		Values:  [][]driver.Value{{int64(5), int64(7), int64(2), int64(1), "", int64(40), int64(45), true}},
+	})
+	fake.On("FROM `message_deletions`", &testutil.Rows{
+		Columns: []string{"id", "user_id", "message_id", "chat_id", "seq_id"},
+		Values:  [][]driver.Value{{int64(1), int64(testUserID), int64(6), int64(7), int64(46)}},
+	})
+
+	result := syncResult(t, r, "last_seq_id=41")
+	assert.Empty(t, result.Edits)
+	assert.ElementsMatch(t, []*service.Tombstone{
+		{MessageID: 5, ChatID: 7},
+		{MessageID: 6, ChatID: 7, ForMe: true},
+	}, result.Deleted)
+	assert.Equal(t, int64(46), result.SeqID)
+
+	deletions := fake.Executed("FROM `message_deletions`")
+	require.Len(t, deletions, 1)
+	assert.Contains(t, deletions[0].Args, driver.Value(int64(41)))
+}
+
+func TestAckMessage_WithoutHub(t *testing.T) {
+	fake, r := newTestRouter(t, nil)
+	fake.On("FROM `chats`", &testutil.Rows{
+		Columns: []string{"id", "type"},
+		Values:  [][]driver.Value{{int64(7), int64(1)}},
+	})
+	fake.On("FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"chat_id", "user_id", "role"},
+		Values:  [][]driver.Value{{int64(7), int64(testUserID), int64(1)}},
+	})
+	fake.On("FROM `messages`", &testutil.Rows{
+		Columns: messageColumns,
+		Values:  [][]driver.Value{{int64(5), int64(7), int64(2), int64(1), "hello", int64(40), int64(0), false}},
+	})
+
+	// 没有 WebSocket Hub 时只记录已读，不通知发送者
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/messages/ack", strings.NewReader(`{"chat_id":7,"message_ids":[5]}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data struct {
+			Acknowledged int `json:"acknowledged"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Data.Acknowledged)
+}
+
+func TestGetPollVoters_RejectsInvalidPaging(t *testing.T) {
+	fake, r := newTestRouter(t, nil)
+
+	for _, query := range []string{"limit=-1", "limit=0", "limit=101", "offset=-1"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/messages/5/poll/voters?option=0&"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.Empty(t, fake.Executed("FROM `poll_votes`"))
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/forever-free1/telegram-go/backend/internal/dto"
+	"github.com/forever-free1/telegram-go/backend/internal/service"
+)
+
+// SearchHandler 搜索处理器
+type SearchHandler struct {
+	searchService *service.SearchService
+}
+
+// NewSearchHandler 创建搜索处理器
+func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// SearchMessages 搜索消息
+// GET /api/messages/search
+// @Summary Search messages
+// @Description Full-text search across the chats the user belongs to. Results are ranked by relevance with highlighted snippets
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text"
+// @Param chat_id query int false "Only search this chat"
+// @Param sender_id query int false "Only messages from this sender"
+// @Param type query int false "Message type"
+// @Param from query string false "Sent at or after (RFC3339)"
+// @Param to query string false "Sent before (RFC3339)"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit"
+// @Success 200 {object} dto.Response
+// @Router /api/messages/search [get]
+func (h *SearchHandler) SearchMessages(c *gin.Context) {
+	var req dto.SearchMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.Error(400, err.Error()))
+		return
+	}
+
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.Error(401, "unauthorized"))
+		return
+	}
+
+	currentUser := user.(*service.UserClaims)
+	result, err := h.searchService.Search(c.Request.Context(), currentUser.UserID, &service.SearchMessagesRequest{
+		Query:    req.Query,
+		ChatID:   req.ChatID,
+		SenderID: req.SenderID,
+		Type:     req.Type,
+		From:     req.From,
+		To:       req.To,
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		code, message := service.ErrorCode(err)
+		c.JSON(code, dto.Error(code, message))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Success(result))
+}
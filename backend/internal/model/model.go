@@ -26,23 +26,49 @@ func (User) TableName() string {
 }
 
 type Message struct {
-	ID         int64          `gorm:"primaryKey;autoIncrement" json:"id"`
-	SeqID      int64          `gorm:"uniqueIndex;not null" json:"seq_id"` // 全局唯一序列号，用于前端去重
-	ChatID     int64          `gorm:"index;not null" json:"chat_id"`
-	SenderID   int64          `gorm:"index;not null" json:"sender_id"`
-	Type       int            `gorm:"type:tinyint;default:1" json:"type"` // 1: text, 2: image, 3: file, 4: voice, 5: location
-	Content    string         `gorm:"type:text" json:"content"`
-	MediaURL   string         `gorm:"size:500" json:"media_url"`
-	Duration   int            `json:"duration"` // for voice
-	Latitude   float64        `json:"latitude"` // for location
-	Longitude  float64        `json:"longitude"`
-	ReplyID    int64          `gorm:"index" json:"reply_id"`
-	IsDeleted  bool           `gorm:"default:false" json:"is_deleted"`
-	IsRead     bool           `gorm:"default:false" json:"is_read"`     // 消息是否已读
-	ReadAt     *time.Time     `json:"read_at"`                         // 消息已读时间
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                     int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SeqID                  int64          `gorm:"uniqueIndex;index:idx_chat_seq,priority:2;not null" json:"seq_id"` // 全局唯一序列号，用于前端去重和分页
+	ChatID                 int64          `gorm:"index:idx_chat_seq,priority:1;index:idx_chat_delivered,priority:1;not null" json:"chat_id"`
+	SenderID               int64          `gorm:"index;uniqueIndex:idx_sender_client_msg,priority:1;not null" json:"sender_id"`
+	Type                   int            `gorm:"type:tinyint;default:1" json:"type"`                                                          // 1: text, 2: image, 3: file, 4: voice, 5: location, 6: poll, 7: service
+	Content                string         `gorm:"type:text;index:idx_messages_content,class:FULLTEXT,option:WITH PARSER ngram" json:"content"` // 全文索引用于消息搜索
+	MediaURL               string         `gorm:"size:500" json:"media_url"`
+	Duration               int            `json:"duration"` // for voice
+	Latitude               float64        `json:"latitude"` // for location
+	Longitude              float64        `json:"longitude"`
+	ReplyID                int64          `gorm:"index" json:"reply_id"`
+	ReplyCount             int            `gorm:"default:0" json:"reply_count"`                                                        // 回复该消息的消息数
+	ClientMsgID            *string        `gorm:"size:64;uniqueIndex:idx_sender_client_msg,priority:2" json:"client_msg_id,omitempty"` // 客户端生成的随机ID，用于重试时去重
+	ForwardedFromChatID    int64          `gorm:"default:0" json:"forwarded_from_chat_id,omitempty"`                                   // 转发来源聊天室
+	ForwardedFromMessageID int64          `gorm:"default:0" json:"forwarded_from_message_id,omitempty"`                                // 转发来源消息
+	ForwardedFromSenderID  int64          `gorm:"default:0" json:"forwarded_from_sender_id,omitempty"`                                 // 原始发送者
+	Signature              string         `gorm:"size:100" json:"signature,omitempty"`                                                 // 频道开启签名时为发布者的昵称
+	Views                  int            `gorm:"default:0" json:"views,omitempty"`                                                    // 频道消息的浏览数，每个订阅者首次读到时加一
+	Action                 string         `gorm:"size:32" json:"action,omitempty"`                                                     // 服务消息的操作类型，例如 chat_renamed
+	IsDeleted              bool           `gorm:"default:false" json:"is_deleted"`
+	DeliveredAt            *time.Time     `gorm:"index:idx_chat_delivered,priority:2" json:"delivered_at"` // 首次送达接收方设备的时间，为空表示仅已发送
+	IsRead                 bool           `gorm:"default:false" json:"is_read"`                            // 消息是否已读
+	ReadAt                 *time.Time     `json:"read_at"`                                                 // 消息已读时间
+	EditedAt               *time.Time     `json:"edited_at"`                                               // 最后编辑时间，未编辑过为空
+	UpdateSeqID            int64          `gorm:"index;default:0" json:"update_seq_id"`                    // 最后一次变更的序列号，与 SeqID 同一序列，用于同步编辑
+	TTL                    int            `gorm:"default:0" json:"ttl,omitempty"`                          // 阅后即焚秒数，从首次被读开始计时
+	ExpiresAt              *time.Time     `gorm:"index" json:"expires_at,omitempty"`                       // 到期后自动删除
+	CreatedAt              time.Time      `json:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at"`
+	DeletedAt              gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Reactions []*ReactionCount `gorm:"-" json:"reactions,omitempty"` // 表情回应汇总，查询消息列表时填充
+	ReplyTo   *MessageQuote    `gorm:"-" json:"reply_to,omitempty"`  // 被回复消息的摘要，被回复消息已删除时为空
+	Poll      *Poll            `gorm:"-" json:"poll,omitempty"`      // 投票消息的投票及当前结果
+}
+
+// MessageQuote 被回复消息的摘要，随回复一起返回，客户端不需要再单独查询
+type MessageQuote struct {
+	ID       int64  `json:"id"`
+	SenderID int64  `json:"sender_id"`
+	Type     int    `json:"type"`
+	Content  string `json:"content"` // 过长时截断
+	MediaURL string `json:"media_url,omitempty"`
 }
 
 func (Message) TableName() string {
@@ -50,37 +76,271 @@ func (Message) TableName() string {
 }
 
 type Chat struct {
-	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name         string         `gorm:"size:100" json:"name"`
-	Type         int            `gorm:"type:tinyint;not null" json:"type"` // 1: private, 2: group, 3: channel
-	Avatar       string         `gorm:"size:500" json:"avatar"`
-	OwnerID      int64          `gorm:"index" json:"owner_id"`
-	MemberCount int            `gorm:"default:0" json:"member_count"`
-	IsVerified   bool           `gorm:"default:false" json:"is_verified"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name               string         `gorm:"size:100" json:"name"`
+	Type               int            `gorm:"type:tinyint;not null" json:"type"` // 1: private, 2: group, 3: channel
+	Avatar             string         `gorm:"size:500" json:"avatar"`
+	Description        string         `gorm:"size:255" json:"description"`
+	OwnerID            int64          `gorm:"index" json:"owner_id"`
+	MemberCount        int            `gorm:"default:0" json:"member_count"`
+	IsVerified         bool           `gorm:"default:false" json:"is_verified"`
+	AvailableReactions []string       `gorm:"serializer:json;type:text" json:"available_reactions"` // 允许使用的表情回应，为空表示不限制
+	MessageTTL         int            `gorm:"default:0" json:"message_ttl"`                         // 自动删除秒数，从发送时开始计时，0 表示不自动删除
+	SignMessages       bool           `gorm:"default:false" json:"sign_messages"`                   // 频道消息是否显示发布者签名
+	UnreadCount        int            `gorm:"-" json:"unread_count"`                                // 当前用户的未读消息数，查询聊天列表时填充
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// 定时消息状态
+const (
+	ScheduledPending   = 1 // 等待发送，发送者可以编辑或取消
+	ScheduledSending   = 2 // 已被发送任务领取
+	ScheduledSent      = 3 // 已发送，MessageID 为发送出的消息
+	ScheduledFailed    = 4 // 到期时已无法发送，例如发送者已离开聊天室
+	ScheduledCancelled = 5 // 发送者已取消
+)
+
+// ScheduledMessage 定时消息，到期前只有发送者可见
+type ScheduledMessage struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatID    int64      `gorm:"index;not null" json:"chat_id"`
+	SenderID  int64      `gorm:"index;not null" json:"sender_id"`
+	Type      int        `gorm:"type:tinyint;default:1" json:"type"`
+	Content   string     `gorm:"type:text" json:"content"`
+	MediaURL  string     `gorm:"size:500" json:"media_url"`
+	Duration  int        `json:"duration"`
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	ReplyID   int64      `json:"reply_id"`
+	TTL       int        `gorm:"default:0" json:"ttl,omitempty"` // 阅后即焚秒数，发送时带到消息上
+	SendAt    time.Time  `gorm:"index:idx_status_send_at,priority:2;not null" json:"send_at"`
+	Status    int        `gorm:"type:tinyint;index:idx_status_send_at,priority:1;default:1" json:"status"` // 见 Scheduled* 常量
+	ClaimedAt *time.Time `json:"-"`                                                                        // 发送任务领取时间，超时未完成会被重新领取
+	MessageID int64      `gorm:"default:0" json:"message_id,omitempty"`                                    // 发送后生成的消息
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
+
+// MessageEdit 消息编辑历史，保存每次编辑前的内容
+type MessageEdit struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID  int64     `gorm:"index;not null" json:"message_id"`
+	EditorID   int64     `gorm:"not null" json:"editor_id"`    // 编辑人，频道中可能是管理员
+	OldContent string    `gorm:"type:text" json:"old_content"` // 编辑前的内容
+	CreatedAt  time.Time `json:"created_at"`                   // 编辑时间
+}
+
+func (MessageEdit) TableName() string {
+	return "message_edits"
+}
+
+// MessageDeletion 用户"仅对自己删除"的消息，其他成员不受影响
+type MessageDeletion struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64     `gorm:"uniqueIndex:idx_user_message,priority:1;index:idx_user_seq,priority:1;not null" json:"user_id"`
+	MessageID int64     `gorm:"uniqueIndex:idx_user_message,priority:2;not null" json:"message_id"`
+	ChatID    int64     `gorm:"not null" json:"chat_id"`
+	SeqID     int64     `gorm:"index:idx_user_seq,priority:2;not null" json:"seq_id"` // 删除时生成的序列号，与消息 SeqID 同一序列，用于多设备同步
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (MessageDeletion) TableName() string {
+	return "message_deletions"
+}
+
+// MessageReaction 消息的表情回应，每个用户对每条消息只保留一个
+type MessageReaction struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID int64     `gorm:"uniqueIndex:idx_message_user,priority:1;not null" json:"message_id"`
+	UserID    int64     `gorm:"uniqueIndex:idx_message_user,priority:2;not null" json:"user_id"`
+	Emoji     string    `gorm:"size:32;not null" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (MessageReaction) TableName() string {
+	return "message_reactions"
+}
+
+// ReactionCount 某个表情在一条消息上的回应数
+type ReactionCount struct {
+	MessageID int64  `json:"-"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+	Chosen    bool   `json:"chosen"` // 当前用户是否使用了该表情
 }
 
 func (Chat) TableName() string {
 	return "chats"
 }
 
-type ChatMember struct {
+// PinnedMessage 聊天室置顶消息，一个聊天室可以置顶多条
+type PinnedMessage struct {
 	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	ChatID    int64     `gorm:"index;not null" json:"chat_id"`
-	UserID    int64     `gorm:"index;not null" json:"user_id"`
-	Role      int       `gorm:"type:tinyint;default:1" json:"role"` // 1: member, 2: admin, 3: owner
-	Nickname  string    `gorm:"size:100" json:"nickname"`
-	JoinedAt  time.Time `json:"joined_at"`
+	ChatID    int64     `gorm:"uniqueIndex:idx_chat_message,priority:1;not null" json:"chat_id"`
+	MessageID int64     `gorm:"uniqueIndex:idx_chat_message,priority:2;not null" json:"message_id"`
+	PinnedBy  int64     `gorm:"not null" json:"pinned_by"`
+	PinnedAt  time.Time `json:"pinned_at"`
+}
+
+func (PinnedMessage) TableName() string {
+	return "pinned_messages"
+}
+
+// Poll 投票，属于一条 type=6 的消息
+// 转发的投票消息与原消息共用同一个投票
+type Poll struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID      int64      `gorm:"uniqueIndex;not null" json:"message_id"`
+	ChatID         int64      `gorm:"index;not null" json:"chat_id"`
+	Question       string     `gorm:"size:300;not null" json:"question"`
+	Options        []string   `gorm:"serializer:json;type:text" json:"options"`
+	PublicVoters   bool       `gorm:"default:false" json:"public_voters"`   // 是否公开投票人，默认匿名
+	MultipleChoice bool       `gorm:"default:false" json:"multiple_choice"` // 是否可以多选，测验不能多选
+	IsQuiz         bool       `gorm:"default:false" json:"is_quiz"`         // 测验模式，有唯一正确答案，投票后不能修改
+	CorrectOption  int        `gorm:"default:0" json:"-"`                   // 测验的正确选项下标，见 Correct
+	CloseAt        *time.Time `gorm:"index" json:"close_at"`                // 到期自动结束，为空表示不限时
+	ClosedAt       *time.Time `json:"closed_at"`                            // 结束的时间，发送者手动结束或到期后由后台任务记录
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Results     []*PollOptionResult `gorm:"-" json:"results"`                  // 各选项的票数，按选项顺序
+	TotalVoters int                 `gorm:"-" json:"total_voters"`             // 参与投票的人数，多选时小于票数之和
+	Closed      bool                `gorm:"-" json:"closed"`                   // 是否已结束
+	Correct     *int                `gorm:"-" json:"correct_option,omitempty"` // 测验的正确选项，当前用户已作答或测验结束后才返回
+}
+
+func (Poll) TableName() string {
+	return "polls"
+}
+
+// IsClosed 投票是否已结束
+func (p *Poll) IsClosed(now time.Time) bool {
+	return p.ClosedAt != nil || (p.CloseAt != nil && !now.Before(*p.CloseAt))
+}
+
+// PollVote 用户的投票，多选时每个选项一条
+type PollVote struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	PollID    int64     `gorm:"uniqueIndex:idx_poll_user_option,priority:1;index:idx_poll_option,priority:1;not null" json:"poll_id"`
+	UserID    int64     `gorm:"uniqueIndex:idx_poll_user_option,priority:2;not null" json:"user_id"`
+	Option    int       `gorm:"uniqueIndex:idx_poll_user_option,priority:3;index:idx_poll_option,priority:2;not null" json:"option"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PollVote) TableName() string {
+	return "poll_votes"
+}
+
+// PollOptionResult 投票某个选项的票数
+type PollOptionResult struct {
+	Option int  `json:"option"`
+	Voters int  `json:"voters"`
+	Chosen bool `json:"chosen"` // 当前用户是否选择了该选项
+}
+
+// ChatReadState 成员在聊天室中的已读位置
+// 群聊中每个成员的已读进度独立，SeqID 之前（含）的消息都视为已读
+type ChatReadState struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"-"`
+	ChatID        int64     `gorm:"uniqueIndex:idx_chat_user,priority:1;index:idx_chat_read_seq,priority:1;not null" json:"chat_id"`
+	UserID        int64     `gorm:"uniqueIndex:idx_chat_user,priority:2;not null" json:"user_id"`
+	LastReadSeqID int64     `gorm:"default:0" json:"last_read_seq_id"`                         // 已读到的消息 SeqID
+	SeqID         int64     `gorm:"index:idx_chat_read_seq,priority:2;not null" json:"seq_id"` // 最后一次前进时生成的序列号，与消息 SeqID 同一序列，用于多设备同步
+	UpdatedAt     time.Time `json:"updated_at"`                                                // 最后一次前进的时间
+}
+
+func (ChatReadState) TableName() string {
+	return "chat_read_states"
+}
+
+type ChatMember struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatID      int64     `gorm:"index;not null" json:"chat_id"`
+	UserID      int64     `gorm:"index;not null" json:"user_id"`
+	Role        int       `gorm:"type:tinyint;default:1" json:"role"` // 1: member, 2: admin, 3: owner
+	Permissions int64     `gorm:"default:0" json:"permissions"`       // 管理员权限位，见 service.Right；所有者拥有全部权限
+	Nickname    string    `gorm:"size:100" json:"nickname"`
+	JoinedAt    time.Time `json:"joined_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 func (ChatMember) TableName() string {
 	return "chat_members"
 }
 
+// ChatInvite 聊天室邀请链接
+type ChatInvite struct {
+	ID               int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatID           int64      `gorm:"index;not null" json:"chat_id"`
+	Code             string     `gorm:"size:32;uniqueIndex;not null" json:"code"`
+	CreatorID        int64      `gorm:"not null" json:"creator_id"`
+	Title            string     `gorm:"size:32" json:"title"`                   // 管理员给链接起的名称，便于区分
+	ExpiresAt        *time.Time `json:"expires_at"`                             // 为空表示不过期
+	MaxUses          int        `gorm:"default:0" json:"max_uses"`              // 0 表示不限次数
+	Uses             int        `gorm:"default:0" json:"uses"`                  // 通过该链接加入的人数
+	RequiresApproval bool       `gorm:"default:false" json:"requires_approval"` // 加入需要管理员批准
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func (ChatInvite) TableName() string {
+	return "chat_invites"
+}
+
+// IsUsable 链接未撤销、未过期且未达到使用次数上限
+func (i *ChatInvite) IsUsable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// ChatJoinRequest 通过需要批准的邀请链接提交的加入申请，批准或拒绝后删除
+type ChatJoinRequest struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatID    int64     `gorm:"uniqueIndex:idx_chat_user,priority:1;not null" json:"chat_id"`
+	UserID    int64     `gorm:"uniqueIndex:idx_chat_user,priority:2;not null" json:"user_id"`
+	InviteID  int64     `gorm:"not null" json:"invite_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ChatJoinRequest) TableName() string {
+	return "chat_join_requests"
+}
+
+// ChatRestriction 群组成员的限制，每个用户在一个聊天室中最多一条
+// 封禁的用户会被移出并且不能再加入；禁言到 MutedUntil 为止不能发送消息；NoMedia 不能发送图片、文件和语音
+type ChatRestriction struct {
+	ID         int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChatID     int64      `gorm:"uniqueIndex:idx_chat_user,priority:1;not null" json:"chat_id"`
+	UserID     int64      `gorm:"uniqueIndex:idx_chat_user,priority:2;not null" json:"user_id"`
+	Banned     bool       `gorm:"default:false" json:"banned"`
+	MutedUntil *time.Time `json:"muted_until,omitempty"`
+	NoMedia    bool       `gorm:"default:false" json:"no_media"`
+	CreatorID  int64      `gorm:"not null" json:"creator_id"` // 设置限制的管理员
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ChatRestriction) TableName() string {
+	return "chat_restrictions"
+}
+
+// IsMuted 判断在 now 时是否处于禁言中
+func (r *ChatRestriction) IsMuted(now time.Time) bool {
+	return r.MutedUntil != nil && now.Before(*r.MutedUntil)
+}
+
 type UserSession struct {
 	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID      int64     `gorm:"index;not null" json:"user_id"`
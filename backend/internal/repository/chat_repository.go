@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/forever-free1/telegram-go/backend/internal/model"
 )
@@ -33,8 +35,14 @@ func (r *ChatRepository) Update(ctx context.Context, chat *model.Chat) error {
 	return r.db.WithContext(ctx).Save(chat).Error
 }
 
+// Delete 删除聊天室并移除全部成员
 func (r *ChatRepository) Delete(ctx context.Context, id int64) error {
-	return r.db.WithContext(ctx).Delete(&model.Chat{}, id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_id = ?", id).Delete(&model.ChatMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Chat{}, id).Error
+	})
 }
 
 func (r *ChatRepository) GetUserChats(ctx context.Context, userID int64) ([]*model.Chat, error) {
@@ -68,6 +76,44 @@ func (r *ChatRepository) GetMember(ctx context.Context, chatID, userID int64) (*
 	return &member, nil
 }
 
+// UpdateMember 更新成员的角色和管理员权限
+func (r *ChatRepository) UpdateMember(ctx context.Context, member *model.ChatMember) error {
+	return r.db.WithContext(ctx).
+		Model(member).
+		Select("role", "permissions").
+		Updates(member).Error
+}
+
+// FindSuccessor 查询所有者离开后接任的成员，优先管理员，同一角色中最早加入的优先
+func (r *ChatRepository) FindSuccessor(ctx context.Context, chatID, ownerID int64) (*model.ChatMember, error) {
+	var member model.ChatMember
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND user_id != ?", chatID, ownerID).
+		Order("role DESC, id ASC").
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// TransferOwnership 把聊天室的所有权转让给 toUserID，原所有者成为普通成员
+func (r *ChatRepository) TransferOwnership(ctx context.Context, chatID, fromUserID, toUserID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Chat{}).Where("id = ?", chatID).Update("owner_id", toUserID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ChatMember{}).
+			Where("chat_id = ? AND user_id = ?", chatID, toUserID).
+			Updates(map[string]interface{}{"role": 3, "permissions": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.ChatMember{}).
+			Where("chat_id = ? AND user_id = ?", chatID, fromUserID).
+			Updates(map[string]interface{}{"role": 1, "permissions": 0}).Error
+	})
+}
+
 func (r *ChatRepository) GetMembers(ctx context.Context, chatID int64) ([]*model.ChatMember, error) {
 	var members []*model.ChatMember
 	err := r.db.WithContext(ctx).
@@ -76,6 +122,55 @@ func (r *ChatRepository) GetMembers(ctx context.Context, chatID int64) ([]*model
 	return members, err
 }
 
+// GetMemberIDsAfter 按用户ID升序分页查询成员ID，用于大聊天室的分批处理
+func (r *ChatRepository) GetMemberIDsAfter(ctx context.Context, chatID, afterUserID int64, limit int) ([]int64, error) {
+	var userIDs []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ChatMember{}).
+		Where("chat_id = ? AND user_id > ?", chatID, afterUserID).
+		Order("user_id ASC").
+		Limit(limit).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// CountMembers 统计聊天室成员数
+func (r *ChatRepository) CountMembers(ctx context.Context, chatID int64) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ChatMember{}).
+		Where("chat_id = ?", chatID).
+		Count(&count).Error
+	return count, err
+}
+
+// PinMessage 置顶消息，已置顶时更新置顶人和时间，使其排到最前
+func (r *ChatRepository) PinMessage(ctx context.Context, pin *model.PinnedMessage) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chat_id"}, {Name: "message_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pinned_by", "pinned_at"}),
+	}).Create(pin).Error
+}
+
+// UnpinMessage 取消置顶，返回是否删除了记录
+func (r *ChatRepository) UnpinMessage(ctx context.Context, chatID, messageID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Delete(&model.PinnedMessage{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetPinnedMessages 获取聊天室的置顶消息，最近置顶的在前
+func (r *ChatRepository) GetPinnedMessages(ctx context.Context, chatID int64) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := r.db.WithContext(ctx).
+		Joins("JOIN pinned_messages ON pinned_messages.message_id = messages.id").
+		Where("pinned_messages.chat_id = ? AND messages.is_deleted = ?", chatID, false).
+		Order("pinned_messages.pinned_at DESC").
+		Find(&messages).Error
+	return messages, err
+}
+
 // GetChatIDsByUserID 获取用户所在的所有聊天室ID
 func (r *ChatRepository) GetChatIDsByUserID(ctx context.Context, userID int64) ([]int64, error) {
 	var chatIDs []int64
@@ -85,3 +180,114 @@ func (r *ChatRepository) GetChatIDsByUserID(ctx context.Context, userID int64) (
 		Pluck("chat_id", &chatIDs).Error
 	return chatIDs, err
 }
+
+// AdvanceReadState 把成员的已读位置前进到 state.LastReadSeqID，不会后退
+// 返回已读位置是否前进；只有前进时才更新 SeqID 和 UpdatedAt
+func (r *ChatRepository) AdvanceReadState(ctx context.Context, state *model.ChatReadState) (bool, error) {
+	// 按顺序赋值，seq_id 和 updated_at 需要在 last_read_seq_id 更新前比较
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chat_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "seq_id"}, Value: gorm.Expr("IF(VALUES(last_read_seq_id) > last_read_seq_id, VALUES(seq_id), seq_id)")},
+			{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("IF(VALUES(last_read_seq_id) > last_read_seq_id, VALUES(updated_at), updated_at)")},
+			{Column: clause.Column{Name: "last_read_seq_id"}, Value: gorm.Expr("GREATEST(last_read_seq_id, VALUES(last_read_seq_id))")},
+		},
+	}).Create(state)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetReadState 查询成员在聊天室中的已读进度
+func (r *ChatRepository) GetReadState(ctx context.Context, chatID, userID int64) (*model.ChatReadState, error) {
+	var state model.ChatReadState
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetReadStates 获取聊天室中已读位置不早于 seqID 的成员
+func (r *ChatRepository) GetReadStates(ctx context.Context, chatID, seqID int64) ([]*model.ChatReadState, error) {
+	var states []*model.ChatReadState
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND last_read_seq_id >= ?", chatID, seqID).
+		Order("updated_at ASC").
+		Find(&states).Error
+	return states, err
+}
+
+// FindReadStatesBetween 查询已读位置在 (afterSeqID, bound] 之间前进过的记录，bound 为 0 时不限上界
+func (r *ChatRepository) FindReadStatesBetween(ctx context.Context, chatIDs []int64, afterSeqID, bound int64, limit int) ([]*model.ChatReadState, error) {
+	var states []*model.ChatReadState
+	query := r.db.WithContext(ctx).Where("chat_id IN ? AND seq_id > ?", chatIDs, afterSeqID)
+	if bound > 0 {
+		query = query.Where("seq_id <= ?", bound)
+	}
+	err := query.Order("seq_id ASC").Limit(limit).Find(&states).Error
+	return states, err
+}
+
+// UnreadCount 用户在某个聊天室的未读消息数
+type UnreadCount struct {
+	ChatID int64
+	Count  int
+}
+
+// CountUnread 统计用户在各聊天室中已读位置之后、其他人发送的消息数，没有未读的聊天室不返回
+func (r *ChatRepository) CountUnread(ctx context.Context, userID int64, chatIDs []int64) ([]*UnreadCount, error) {
+	var counts []*UnreadCount
+	if len(chatIDs) == 0 {
+		return counts, nil
+	}
+	err := r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Select("messages.chat_id, COUNT(*) AS count").
+		Joins("LEFT JOIN chat_read_states ON chat_read_states.chat_id = messages.chat_id AND chat_read_states.user_id = ?", userID).
+		Where("messages.chat_id IN ? AND messages.sender_id != ? AND messages.is_deleted = ?", chatIDs, userID, false).
+		Where("messages.seq_id > COALESCE(chat_read_states.last_read_seq_id, 0)").
+		Where(notDeletedFor, userID).
+		Group("messages.chat_id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// SaveRestriction 保存成员的限制，已有限制时覆盖
+func (r *ChatRepository) SaveRestriction(ctx context.Context, restriction *model.ChatRestriction) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chat_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"banned", "muted_until", "no_media", "creator_id", "updated_at"}),
+	}).Create(restriction).Error
+}
+
+// GetRestriction 查询成员在聊天室中的限制
+func (r *ChatRepository) GetRestriction(ctx context.Context, chatID, userID int64) (*model.ChatRestriction, error) {
+	var restriction model.ChatRestriction
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		First(&restriction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// GetRestrictions 获取聊天室中在 now 时仍然有效的限制，禁言已经到期且没有其他限制的不返回
+func (r *ChatRepository) GetRestrictions(ctx context.Context, chatID int64, now time.Time) ([]*model.ChatRestriction, error) {
+	var restrictions []*model.ChatRestriction
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ?", chatID).
+		Where("banned = ? OR no_media = ? OR muted_until > ?", true, true, now).
+		Order("id ASC").
+		Find(&restrictions).Error
+	return restrictions, err
+}
+
+// DeleteRestriction 解除成员的限制，返回是否存在限制
+func (r *ChatRepository) DeleteRestriction(ctx context.Context, chatID, userID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Delete(&model.ChatRestriction{})
+	return result.RowsAffected > 0, result.Error
+}
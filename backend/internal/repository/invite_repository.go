@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+type InviteRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+func (r *InviteRepository) Create(ctx context.Context, invite *model.ChatInvite) error {
+	return r.db.WithContext(ctx).Create(invite).Error
+}
+
+func (r *InviteRepository) FindByID(ctx context.Context, id int64) (*model.ChatInvite, error) {
+	var invite model.ChatInvite
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&invite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *InviteRepository) FindByCode(ctx context.Context, code string) (*model.ChatInvite, error) {
+	var invite model.ChatInvite
+	err := r.db.WithContext(ctx).Where("code = ?", code).First(&invite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// FindByChatID 查询聊天室的邀请链接，新创建的在前
+func (r *InviteRepository) FindByChatID(ctx context.Context, chatID int64) ([]*model.ChatInvite, error) {
+	var invites []*model.ChatInvite
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ?", chatID).
+		Order("id DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// Revoke 撤销邀请链接，返回是否撤销了尚未撤销的链接
+func (r *InviteRepository) Revoke(ctx context.Context, id int64, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ChatInvite{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Use 链接仍可用时使用次数加一，返回是否成功
+// 条件更新保证并发加入时不会超过使用次数上限
+func (r *InviteRepository) Use(ctx context.Context, id int64, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ChatInvite{}).
+		Where("id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses = 0 OR uses < max_uses)", id, now).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateJoinRequest 提交加入申请，已有待处理的申请时保留原申请
+func (r *InviteRepository) CreateJoinRequest(ctx context.Context, request *model.ChatJoinRequest) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(request).Error
+}
+
+// FindJoinRequests 查询聊天室待处理的加入申请，先提交的在前
+func (r *InviteRepository) FindJoinRequests(ctx context.Context, chatID int64) ([]*model.ChatJoinRequest, error) {
+	var requests []*model.ChatJoinRequest
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ?", chatID).
+		Order("id ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// DeleteJoinRequest 删除加入申请，返回是否删除了记录
+func (r *InviteRepository) DeleteJoinRequest(ctx context.Context, chatID, userID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Delete(&model.ChatJoinRequest{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/forever-free1/telegram-go/backend/internal/model"
 )
@@ -29,30 +31,214 @@ func (r *MessageRepository) FindByID(ctx context.Context, id int64) (*model.Mess
 	return &message, nil
 }
 
-func (r *MessageRepository) FindByChatID(ctx context.Context, chatID int64, offset, limit int) ([]*model.Message, error) {
+// FindByIDs 根据ID批量查询消息，按 SeqID 从早到晚排序
+func (r *MessageRepository) FindByIDs(ctx context.Context, ids []int64) ([]*model.Message, error) {
 	var messages []*model.Message
 	err := r.db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Order("seq_id ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// CreateBatch 在同一事务中批量创建消息
+func (r *MessageRepository) CreateBatch(ctx context.Context, messages []*model.Message) error {
+	return r.db.WithContext(ctx).Create(messages).Error
+}
+
+// FindByClientMsgID 根据发送者和客户端消息ID查询消息，用于幂等发送
+func (r *MessageRepository) FindByClientMsgID(ctx context.Context, senderID int64, clientMsgID string) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).
+		Where("sender_id = ? AND client_msg_id = ?", senderID, clientMsgID).
+		First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// notDeletedFor 排除用户仅对自己删除的消息
+const notDeletedFor = "NOT EXISTS (SELECT 1 FROM message_deletions WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = ?)"
+
+// FindByChatIDBefore 查询聊天室中 SeqID 小于 beforeSeqID 的消息，按 SeqID 从新到旧
+// beforeSeqID 为 0 时从最新的消息开始
+func (r *MessageRepository) FindByChatIDBefore(ctx context.Context, chatID, userID, beforeSeqID int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	query := r.db.WithContext(ctx).
 		Where("chat_id = ? AND is_deleted = ?", chatID, false).
-		Order("created_at DESC").
-		Offset(offset).
+		Where(notDeletedFor, userID)
+	if beforeSeqID != 0 {
+		query = query.Where("seq_id < ?", beforeSeqID)
+	}
+	err := query.Order("seq_id DESC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// FindByChatIDAfter 查询聊天室中 SeqID 大于 afterSeqID 的消息，按 SeqID 从旧到新
+func (r *MessageRepository) FindByChatIDAfter(ctx context.Context, chatID, userID, afterSeqID int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND seq_id > ? AND is_deleted = ?", chatID, afterSeqID, false).
+		Where(notDeletedFor, userID).
+		Order("seq_id ASC").
 		Limit(limit).
 		Find(&messages).Error
 	return messages, err
 }
 
-func (r *MessageRepository) Delete(ctx context.Context, id int64) error {
-	return r.db.WithContext(ctx).Model(&model.Message{}).Where("id = ?", id).Update("is_deleted", true).Error
+// Delete 对所有人删除消息，保留记录作为同步用的墓碑
+func (r *MessageRepository) Delete(ctx context.Context, id, updateSeqID int64) error {
+	return r.db.WithContext(ctx).Model(&model.Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_deleted":    true,
+		"update_seq_id": updateSeqID,
+	}).Error
+}
+
+// CreateDeletion 记录用户仅对自己删除的消息，重复删除时忽略
+func (r *MessageRepository) CreateDeletion(ctx context.Context, deletion *model.MessageDeletion) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(deletion).Error
+}
+
+// FindDeletionsBetween 查询用户在 (lastSeqID, maxSeqID] 之间仅对自己删除的消息
+// maxSeqID 为 0 表示不限制上界
+func (r *MessageRepository) FindDeletionsBetween(ctx context.Context, userID, lastSeqID, maxSeqID int64, limit int) ([]*model.MessageDeletion, error) {
+	var deletions []*model.MessageDeletion
+	query := r.db.WithContext(ctx).Where("user_id = ? AND seq_id > ?", userID, lastSeqID)
+	if maxSeqID > 0 {
+		query = query.Where("seq_id <= ?", maxSeqID)
+	}
+	err := query.
+		Order("seq_id ASC").
+		Limit(limit).
+		Find(&deletions).Error
+	return deletions, err
 }
 
 func (r *MessageRepository) Update(ctx context.Context, message *model.Message) error {
 	return r.db.WithContext(ctx).Save(message).Error
 }
 
+// IncrementReplyCount 调整消息的回复数，不会小于 0
+func (r *MessageRepository) IncrementReplyCount(ctx context.Context, id int64, delta int) error {
+	return r.db.WithContext(ctx).Model(&model.Message{}).Where("id = ?", id).
+		UpdateColumn("reply_count", gorm.Expr("GREATEST(reply_count + ?, 0)", delta)).Error
+}
+
+// FindReplies 分页查询回复某条消息的消息，按时间从早到晚
+// 用户仅对自己删除的消息不会返回
+func (r *MessageRepository) FindReplies(ctx context.Context, replyID, userID int64, offset, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := r.db.WithContext(ctx).
+		Where("reply_id = ? AND is_deleted = ?", replyID, false).
+		Where(notDeletedFor, userID).
+		Order("seq_id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// FindExpired 查询已到期但还未删除的消息
+func (r *MessageRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := r.db.WithContext(ctx).
+		Where("expires_at <= ? AND is_deleted = ?", now, false).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// CountByMediaURL 统计仍引用该媒体文件的未删除消息数（转发的消息共用同一个文件）
+func (r *MessageRepository) CountByMediaURL(ctx context.Context, mediaURL string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Where("media_url = ? AND is_deleted = ?", mediaURL, false).
+		Count(&count).Error
+	return count, err
+}
+
+// SaveEdit 保存消息编辑，同时记录编辑前的内容
+func (r *MessageRepository) SaveEdit(ctx context.Context, message *model.Message, edit *model.MessageEdit) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(edit).Error; err != nil {
+			return err
+		}
+		return tx.Model(message).Updates(map[string]interface{}{
+			"content":       message.Content,
+			"edited_at":     message.EditedAt,
+			"update_seq_id": message.UpdateSeqID,
+		}).Error
+	})
+}
+
+// FindEdits 查询消息的编辑历史，按时间从早到晚
+func (r *MessageRepository) FindEdits(ctx context.Context, messageID int64) ([]*model.MessageEdit, error) {
+	var edits []*model.MessageEdit
+	err := r.db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("id ASC").
+		Find(&edits).Error
+	return edits, err
+}
+
+// FindUpdatedBetween 查询 SeqID 不大于 lastSeqID、但在 (lastSeqID, maxUpdateSeqID] 之间被编辑或删除过的消息
+// maxUpdateSeqID 为 0 表示不限制上界
+func (r *MessageRepository) FindUpdatedBetween(ctx context.Context, chatIDs []int64, lastSeqID, maxUpdateSeqID int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	query := r.db.WithContext(ctx).
+		Where("chat_id IN ? AND seq_id <= ? AND update_seq_id > ?", chatIDs, lastSeqID, lastSeqID)
+	if maxUpdateSeqID > 0 {
+		query = query.Where("update_seq_id <= ?", maxUpdateSeqID)
+	}
+	err := query.
+		Order("update_seq_id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// SetReaction 设置用户对消息的表情回应，已有回应时替换
+func (r *MessageRepository) SetReaction(ctx context.Context, reaction *model.MessageReaction) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"emoji", "updated_at"}),
+	}).Create(reaction).Error
+}
+
+// DeleteReaction 删除用户对消息的表情回应，返回是否删除了记录
+func (r *MessageRepository) DeleteReaction(ctx context.Context, messageID, userID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ?", messageID, userID).
+		Delete(&model.MessageReaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindReactionCounts 按消息和表情汇总回应数，并标记 userID 自己使用的表情
+func (r *MessageRepository) FindReactionCounts(ctx context.Context, messageIDs []int64, userID int64) ([]*model.ReactionCount, error) {
+	var counts []*model.ReactionCount
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+	err := r.db.WithContext(ctx).
+		Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) > 0 AS chosen", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("count DESC, emoji ASC").
+		Scan(&counts).Error
+	return counts, err
+}
+
 // FindBySeqIDsGreaterThan 查询SeqID大于指定值且属于指定聊天室的消息
-func (r *MessageRepository) FindBySeqIDsGreaterThan(ctx context.Context, chatIDs []int64, lastSeqID int64, limit int) ([]*model.Message, error) {
+// 用户仅对自己删除的消息不会返回
+func (r *MessageRepository) FindBySeqIDsGreaterThan(ctx context.Context, chatIDs []int64, userID, lastSeqID int64, limit int) ([]*model.Message, error) {
 	var messages []*model.Message
 	err := r.db.WithContext(ctx).
 		Where("chat_id IN ? AND seq_id > ? AND is_deleted = ?", chatIDs, lastSeqID, false).
+		Where(notDeletedFor, userID).
 		Order("seq_id ASC").
 		Limit(limit).
 		Find(&messages).Error
@@ -75,12 +261,17 @@ func (r *MessageRepository) AckMessages(ctx context.Context, userID, chatID int6
 	now := r.db.NowFunc()
 
 	// 只标记不是自己发送的消息为已读
+	// 设置了阅后即焚的消息从首次被读开始计时，已有更早的到期时间时保留
 	result := r.db.WithContext(ctx).
 		Model(&model.Message{}).
 		Where("chat_id = ? AND sender_id != ? AND id IN ? AND is_read = ?", chatID, userID, messageIDs, false).
 		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": now,
+			"is_read":      true,
+			"read_at":      now,
+			"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+			"expires_at": gorm.Expr(
+				"CASE WHEN ttl > 0 AND (expires_at IS NULL OR expires_at > DATE_ADD(?, INTERVAL ttl SECOND)) THEN DATE_ADD(?, INTERVAL ttl SECOND) ELSE expires_at END",
+				now, now),
 		})
 
 	if result.Error != nil {
@@ -90,8 +281,48 @@ func (r *MessageRepository) AckMessages(ctx context.Context, userID, chatID int6
 	// 查询被更新的消息，返回给调用者用于通知发送者
 	var messages []*model.Message
 	err := r.db.WithContext(ctx).
-		Where("chat_id = ? AND sender_id != ? AND id IN ?", chatID, userID, messageIDs).
+		Where("chat_id = ? AND sender_id != ? AND id IN ? AND is_deleted = ?", chatID, userID, messageIDs, false).
 		Find(&messages).Error
 
 	return messages, err
 }
+
+// FindUndelivered 查询用户所在聊天室中其他用户发送、尚未送达的消息
+// messageIDs 不为空时只查询指定的消息，否则查询 SeqID 不大于 maxSeqID 的消息
+func (r *MessageRepository) FindUndelivered(ctx context.Context, chatIDs []int64, userID int64, messageIDs []int64, maxSeqID int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	query := r.db.WithContext(ctx).
+		Where("chat_id IN ? AND sender_id != ? AND delivered_at IS NULL AND is_deleted = ?", chatIDs, userID, false)
+	if len(messageIDs) > 0 {
+		query = query.Where("id IN ?", messageIDs)
+	} else {
+		query = query.Where("seq_id <= ?", maxSeqID)
+	}
+	err := query.
+		Order("seq_id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// MarkDelivered 记录消息的送达时间，已送达的消息保持首次送达时间不变
+func (r *MessageRepository) MarkDelivered(ctx context.Context, ids []int64, deliveredAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Where("id IN ? AND delivered_at IS NULL", ids).
+		Update("delivered_at", deliveredAt).Error
+}
+
+// IncrementViews 频道消息的浏览数加一
+func (r *MessageRepository) IncrementViews(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Where("id IN ?", ids).
+		UpdateColumn("views", gorm.Expr("views + 1")).Error
+}
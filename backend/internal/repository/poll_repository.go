@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+type PollRepository struct {
+	db *gorm.DB
+}
+
+func NewPollRepository(db *gorm.DB) *PollRepository {
+	return &PollRepository{db: db}
+}
+
+// Create 在同一个事务中保存投票消息和投票
+func (r *PollRepository) Create(ctx context.Context, message *model.Message, poll *model.Poll) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		poll.MessageID = message.ID
+		poll.ChatID = message.ChatID
+		return tx.Create(poll).Error
+	})
+}
+
+func (r *PollRepository) FindByMessageID(ctx context.Context, messageID int64) (*model.Poll, error) {
+	var poll model.Poll
+	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).First(&poll).Error
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (r *PollRepository) FindByMessageIDs(ctx context.Context, messageIDs []int64) ([]*model.Poll, error) {
+	var polls []*model.Poll
+	if len(messageIDs) == 0 {
+		return polls, nil
+	}
+	err := r.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Find(&polls).Error
+	return polls, err
+}
+
+// Close 手动结束投票，已结束时不更新
+func (r *PollRepository) Close(ctx context.Context, pollID int64, now time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Poll{}).
+		Where("id = ? AND closed_at IS NULL", pollID).
+		Update("closed_at", now).Error
+}
+
+// FindExpired 查询已到自动结束时间但还没有记录结束的投票
+func (r *PollRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]*model.Poll, error) {
+	var polls []*model.Poll
+	err := r.db.WithContext(ctx).
+		Where("closed_at IS NULL AND close_at <= ?", now).
+		Order("close_at ASC").
+		Limit(limit).
+		Find(&polls).Error
+	return polls, err
+}
+
+// CloseExpired 把到期投票的结束时间记录为自动结束时间，多个节点同时处理时只有一个会成功
+func (r *PollRepository) CloseExpired(ctx context.Context, pollID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Poll{}).
+		Where("id = ? AND closed_at IS NULL", pollID).
+		Update("closed_at", gorm.Expr("close_at"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReplaceVotes 用新的选项替换用户之前的投票
+func (r *PollRepository) ReplaceVotes(ctx context.Context, pollID, userID int64, options []int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("poll_id = ? AND user_id = ?", pollID, userID).Delete(&model.PollVote{}).Error; err != nil {
+			return err
+		}
+		votes := make([]*model.PollVote, 0, len(options))
+		for _, option := range options {
+			votes = append(votes, &model.PollVote{PollID: pollID, UserID: userID, Option: option})
+		}
+		return tx.Create(&votes).Error
+	})
+}
+
+// DeleteVotes 撤回用户的投票，返回是否有投票被撤回
+func (r *PollRepository) DeleteVotes(ctx context.Context, pollID, userID int64) (bool, error) {
+	result := r.db.WithContext(ctx).Where("poll_id = ? AND user_id = ?", pollID, userID).Delete(&model.PollVote{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AnswerQuiz 保存测验的答案，用户已经作答时不修改并返回 false
+// 先锁定投票行再检查是否已作答，同一用户的并发请求只有一个能保存
+func (r *PollRepository) AnswerQuiz(ctx context.Context, pollID, userID int64, options []int) (bool, error) {
+	answered := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var poll model.Poll
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&poll, pollID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.PollVote{}).Where("poll_id = ? AND user_id = ?", pollID, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		votes := make([]*model.PollVote, 0, len(options))
+		for _, option := range options {
+			votes = append(votes, &model.PollVote{PollID: pollID, UserID: userID, Option: option})
+		}
+		if err := tx.Create(&votes).Error; err != nil {
+			return err
+		}
+		answered = true
+		return nil
+	})
+	return answered, err
+}
+
+// PollOptionCount 某个选项的票数
+type PollOptionCount struct {
+	PollID int64
+	Option int
+	Count  int
+}
+
+// FindOptionCounts 统计投票各选项的票数
+func (r *PollRepository) FindOptionCounts(ctx context.Context, pollIDs []int64) ([]*PollOptionCount, error) {
+	var counts []*PollOptionCount
+	err := r.db.WithContext(ctx).
+		Model(&model.PollVote{}).
+		Select("poll_id, `option`, COUNT(*) AS count").
+		Where("poll_id IN ?", pollIDs).
+		Group("poll_id, `option`").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// PollVoterCount 参与投票的人数
+type PollVoterCount struct {
+	PollID int64
+	Count  int
+}
+
+// FindVoterCounts 统计参与各投票的人数
+func (r *PollRepository) FindVoterCounts(ctx context.Context, pollIDs []int64) ([]*PollVoterCount, error) {
+	var counts []*PollVoterCount
+	err := r.db.WithContext(ctx).
+		Model(&model.PollVote{}).
+		Select("poll_id, COUNT(DISTINCT user_id) AS count").
+		Where("poll_id IN ?", pollIDs).
+		Group("poll_id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// FindUserVotes 查询用户在这些投票中的选择
+func (r *PollRepository) FindUserVotes(ctx context.Context, pollIDs []int64, userID int64) ([]*model.PollVote, error) {
+	var votes []*model.PollVote
+	err := r.db.WithContext(ctx).
+		Where("poll_id IN ? AND user_id = ?", pollIDs, userID).
+		Find(&votes).Error
+	return votes, err
+}
+
+// FindVoters 分页查询选择了某个选项的用户，按投票时间排序
+func (r *PollRepository) FindVoters(ctx context.Context, pollID int64, option, offset, limit int) ([]int64, error) {
+	var userIDs []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.PollVote{}).
+		Where("poll_id = ? AND `option` = ?", pollID, option).
+		Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+type ScheduledMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepository(db *gorm.DB) *ScheduledMessageRepository {
+	return &ScheduledMessageRepository{db: db}
+}
+
+func (r *ScheduledMessageRepository) Create(ctx context.Context, message *model.ScheduledMessage) error {
+	return r.db.WithContext(ctx).Create(message).Error
+}
+
+func (r *ScheduledMessageRepository) FindByID(ctx context.Context, id int64) (*model.ScheduledMessage, error) {
+	var message model.ScheduledMessage
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// FindPending 查询发送者等待发送的定时消息，chatID 为 0 时查询所有聊天室
+func (r *ScheduledMessageRepository) FindPending(ctx context.Context, senderID, chatID int64) ([]*model.ScheduledMessage, error) {
+	var messages []*model.ScheduledMessage
+	query := r.db.WithContext(ctx).Where("sender_id = ? AND status = ?", senderID, model.ScheduledPending)
+	if chatID != 0 {
+		query = query.Where("chat_id = ?", chatID)
+	}
+	err := query.Order("send_at ASC").Find(&messages).Error
+	return messages, err
+}
+
+// UpdatePending 更新等待发送的定时消息，已被领取或发送时不更新
+// 返回是否更新了记录
+func (r *ScheduledMessageRepository) UpdatePending(ctx context.Context, id, senderID int64, updates map[string]interface{}) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ScheduledMessage{}).
+		Where("id = ? AND sender_id = ? AND status = ?", id, senderID, model.ScheduledPending).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindDue 查询到期待发送的定时消息，以及领取后超过 staleBefore 仍未完成的消息
+func (r *ScheduledMessageRepository) FindDue(ctx context.Context, now, staleBefore time.Time, limit int) ([]*model.ScheduledMessage, error) {
+	var messages []*model.ScheduledMessage
+	err := r.db.WithContext(ctx).
+		Where("(status = ? AND send_at <= ?) OR (status = ? AND claimed_at < ?)",
+			model.ScheduledPending, now, model.ScheduledSending, staleBefore).
+		Order("send_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// Claim 领取定时消息，多个节点同时领取时只有一个会成功
+func (r *ScheduledMessageRepository) Claim(ctx context.Context, id int64, now, staleBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.ScheduledMessage{}).
+		Where("id = ? AND (status = ? OR (status = ? AND claimed_at < ?))",
+			id, model.ScheduledPending, model.ScheduledSending, staleBefore).
+		Updates(map[string]interface{}{
+			"status":     model.ScheduledSending,
+			"claimed_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Finish 标记定时消息发送完成或失败
+func (r *ScheduledMessageRepository) Finish(ctx context.Context, id int64, status int, messageID int64) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"message_id": messageID,
+		}).Error
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+// memoryDoc 内存索引中的一条消息
+type memoryDoc struct {
+	message *model.Message
+	terms   map[string]int // 搜索词出现的次数
+	hidden  map[int64]bool // 仅对自己删除了该消息的用户
+}
+
+// MemoryIndex 纯 Go 的内存索引，用于测试和不依赖 MySQL 的场景
+// 相关度为各搜索词的 TF-IDF 之和，任意一个搜索词匹配即返回
+type MemoryIndex struct {
+	mu   sync.RWMutex
+	docs map[int64]*memoryDoc
+	df   map[string]int // 包含每个搜索词的消息数
+}
+
+var _ Index = (*MemoryIndex)(nil)
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs: make(map[int64]*memoryDoc),
+		df:   make(map[string]int),
+	}
+}
+
+func (m *MemoryIndex) Index(ctx context.Context, message *model.Message) error {
+	if message.IsDeleted {
+		return m.Remove(ctx, message.ID)
+	}
+
+	terms := make(map[string]int)
+	for _, term := range Tokenize(message.Content) {
+		terms[term]++
+	}
+
+	copied := *message
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hidden := make(map[int64]bool)
+	if old, ok := m.docs[message.ID]; ok {
+		hidden = old.hidden
+		m.removeLocked(message.ID)
+	}
+	m.docs[message.ID] = &memoryDoc{message: &copied, terms: terms, hidden: hidden}
+	for term := range terms {
+		m.df[term]++
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Remove(ctx context.Context, messageID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(messageID)
+	return nil
+}
+
+func (m *MemoryIndex) removeLocked(messageID int64) {
+	doc, ok := m.docs[messageID]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		if m.df[term]--; m.df[term] <= 0 {
+			delete(m.df, term)
+		}
+	}
+	delete(m.docs, messageID)
+}
+
+func (m *MemoryIndex) RemoveFor(ctx context.Context, userID, messageID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if doc, ok := m.docs[messageID]; ok {
+		doc.hidden[userID] = true
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Search(ctx context.Context, query *Query) (*Result, error) {
+	terms := uniqueTerms(Tokenize(query.Text))
+	chats := make(map[int64]bool, len(query.ChatIDs))
+	for _, chatID := range query.ChatIDs {
+		chats[chatID] = true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := float64(len(m.docs))
+	hits := make([]*Hit, 0)
+	for id, doc := range m.docs {
+		if !matches(doc, query, chats) {
+			continue
+		}
+		var score float64
+		for _, term := range terms {
+			if tf := doc.terms[term]; tf > 0 {
+				score += float64(tf) * math.Log(1+total/float64(m.df[term]))
+			}
+		}
+		if score > 0 {
+			hits = append(hits, &Hit{MessageID: id, Score: score})
+		}
+	}
+	sortHits(hits)
+
+	result := &Result{Hits: []*Hit{}, Total: int64(len(hits))}
+	if query.Offset >= len(hits) {
+		return result, nil
+	}
+	end := len(hits)
+	if query.Limit > 0 {
+		end = min(query.Offset+query.Limit, len(hits))
+	}
+	for _, hit := range hits[query.Offset:end] {
+		hit.Snippet = Snippet(m.docs[hit.MessageID].message.Content, terms)
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+// matches 判断消息是否符合搜索条件中除搜索词以外的过滤条件
+func matches(doc *memoryDoc, query *Query, chats map[int64]bool) bool {
+	message := doc.message
+	switch {
+	case !chats[message.ChatID], doc.hidden[query.UserID]:
+		return false
+	case query.SenderID != 0 && message.SenderID != query.SenderID:
+		return false
+	case query.Type != 0 && message.Type != query.Type:
+		return false
+	case !query.From.IsZero() && message.CreatedAt.Before(query.From):
+		return false
+	case !query.To.IsZero() && !message.CreatedAt.Before(query.To):
+		return false
+	}
+	return true
+}
+
+// sortHits 按相关度排序，相关度相同时新消息在前
+func sortHits(hits []*Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].MessageID > hits[j].MessageID
+	})
+}
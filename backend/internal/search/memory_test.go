@@ -0,0 +1,102 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+func newTestIndex(t *testing.T, messages ...*model.Message) *MemoryIndex {
+	t.Helper()
+	index := NewMemoryIndex()
+	for _, message := range messages {
+		require.NoError(t, index.Index(context.Background(), message))
+	}
+	return index
+}
+
+func hitIDs(result *Result) []int64 {
+	ids := make([]int64, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.MessageID)
+	}
+	return ids
+}
+
+func TestMemoryIndex_RankAndRestrictToChats(t *testing.T) {
+	index := newTestIndex(t,
+		&model.Message{ID: 1, ChatID: 10, Type: 1, Content: "meeting at noon"},
+		&model.Message{ID: 2, ChatID: 10, Type: 1, Content: "meeting moved, the meeting is at 3"},
+		&model.Message{ID: 3, ChatID: 20, Type: 1, Content: "secret meeting"},
+		&model.Message{ID: 4, ChatID: 10, Type: 1, Content: "lunch"},
+	)
+
+	result, err := index.Search(context.Background(), &Query{Text: "Meeting", ChatIDs: []int64{10}, Limit: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), result.Total)
+	assert.Equal(t, []int64{2, 1}, hitIDs(result))
+	assert.Equal(t, "<mark>meeting</mark> at noon", result.Hits[1].Snippet)
+}
+
+func TestMemoryIndex_Filters(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	index := newTestIndex(t,
+		&model.Message{ID: 1, ChatID: 10, SenderID: 100, Type: 1, Content: "report draft", CreatedAt: base},
+		&model.Message{ID: 2, ChatID: 10, SenderID: 200, Type: 1, Content: "report final", CreatedAt: base.Add(24 * time.Hour)},
+		&model.Message{ID: 3, ChatID: 10, SenderID: 100, Type: 6, Content: "report poll", CreatedAt: base.Add(48 * time.Hour)},
+	)
+	ctx := context.Background()
+
+	result, err := index.Search(ctx, &Query{Text: "report", ChatIDs: []int64{10}, SenderID: 100})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 3}, hitIDs(result))
+
+	result, err = index.Search(ctx, &Query{Text: "report", ChatIDs: []int64{10}, Type: 6})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, hitIDs(result))
+
+	result, err = index.Search(ctx, &Query{Text: "report", ChatIDs: []int64{10}, From: base.Add(time.Hour), To: base.Add(48 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+}
+
+func TestMemoryIndex_RemoveAndUpdate(t *testing.T) {
+	index := newTestIndex(t,
+		&model.Message{ID: 1, ChatID: 10, Content: "hello world"},
+		&model.Message{ID: 2, ChatID: 10, Content: "hello there"},
+		&model.Message{ID: 3, ChatID: 10, Content: "hello again"},
+	)
+	ctx := context.Background()
+
+	require.NoError(t, index.Remove(ctx, 1))
+	require.NoError(t, index.RemoveFor(ctx, 100, 2))
+	require.NoError(t, index.Index(ctx, &model.Message{ID: 3, ChatID: 10, Content: "goodbye"}))
+
+	result, err := index.Search(ctx, &Query{Text: "hello", UserID: 100, ChatIDs: []int64{10}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	// 仅对自己删除的消息其他用户仍然可以搜索到
+	result, err = index.Search(ctx, &Query{Text: "hello", UserID: 200, ChatIDs: []int64{10}})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+}
+
+func TestMemoryIndex_Pagination(t *testing.T) {
+	index := newTestIndex(t,
+		&model.Message{ID: 1, ChatID: 10, Content: "ping"},
+		&model.Message{ID: 2, ChatID: 10, Content: "ping"},
+		&model.Message{ID: 3, ChatID: 10, Content: "ping"},
+	)
+
+	result, err := index.Search(context.Background(), &Query{Text: "ping", ChatIDs: []int64{10}, Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+}
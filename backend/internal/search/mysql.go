@@ -0,0 +1,97 @@
+package search
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+// matchAgainst messages.content 上的 FULLTEXT 索引（ngram 分词器）匹配条件
+const matchAgainst = "MATCH(content) AGAINST(? IN NATURAL LANGUAGE MODE)"
+
+// MySQLIndex 使用 messages 表 FULLTEXT 索引的搜索实现
+// 索引由 MySQL 随消息的增删改自动维护，Index/Remove/RemoveFor 不需要做任何事
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+var _ Index = (*MySQLIndex)(nil)
+
+func NewMySQLIndex(db *gorm.DB) *MySQLIndex {
+	return &MySQLIndex{db: db}
+}
+
+func (m *MySQLIndex) Index(ctx context.Context, message *model.Message) error {
+	return nil
+}
+
+func (m *MySQLIndex) Remove(ctx context.Context, messageID int64) error {
+	return nil
+}
+
+func (m *MySQLIndex) RemoveFor(ctx context.Context, userID, messageID int64) error {
+	return nil
+}
+
+func (m *MySQLIndex) Search(ctx context.Context, query *Query) (*Result, error) {
+	result := &Result{Hits: []*Hit{}}
+	if len(query.ChatIDs) == 0 {
+		return result, nil
+	}
+
+	if err := m.filter(ctx, query).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		ID      int64
+		Content string
+		Score   float64
+	}
+	err := m.filter(ctx, query).
+		Select("id, content, "+matchAgainst+" AS score", query.Text).
+		Order("score DESC, id DESC").
+		Offset(query.Offset).
+		Limit(query.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	terms := uniqueTerms(Tokenize(query.Text))
+	for _, row := range rows {
+		result.Hits = append(result.Hits, &Hit{
+			MessageID: row.ID,
+			Score:     row.Score,
+			Snippet:   Snippet(row.Content, terms),
+		})
+	}
+	return result, nil
+}
+
+// filter 构造搜索条件
+func (m *MySQLIndex) filter(ctx context.Context, query *Query) *gorm.DB {
+	db := m.db.WithContext(ctx).
+		Model(&model.Message{}).
+		Where(matchAgainst, query.Text).
+		Where("chat_id IN ? AND is_deleted = ?", query.ChatIDs, false).
+		Where("NOT EXISTS (SELECT 1 FROM message_deletions WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = ?)", query.UserID)
+	if query.SenderID != 0 {
+		db = db.Where("sender_id = ?", query.SenderID)
+	}
+	if query.Type != 0 {
+		db = db.Where("type = ?", query.Type)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at < ?", query.To)
+	}
+	return db
+}
@@ -0,0 +1,177 @@
+// Package search 消息全文搜索
+// Index 定义索引接口，MySQLIndex 直接使用 messages 表上的 FULLTEXT 索引，MemoryIndex 为纯 Go 的内存实现，用于测试
+package search
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+// 高亮片段中匹配词的标记
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// snippetLength 片段最多保留的字符数（不含高亮标记）
+const snippetLength = 120
+
+// Query 搜索条件
+// ChatIDs 为可以搜索的聊天室，由调用方限制为用户所在的聊天室；其他条件为零值时不过滤
+type Query struct {
+	Text     string
+	UserID   int64 // 搜索人，排除其仅对自己删除的消息
+	ChatIDs  []int64
+	SenderID int64
+	Type     int
+	From     time.Time // 发送时间不早于 From
+	To       time.Time // 发送时间早于 To
+	Offset   int
+	Limit    int
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	MessageID int64   `json:"message_id"`
+	Score     float64 `json:"score"`
+	Snippet   string  `json:"snippet"` // 包含匹配词的片段，匹配词用 HighlightStart/HighlightEnd 包裹，其余文本已做 HTML 转义
+}
+
+// Result 一页搜索结果，按相关度从高到低排序，相关度相同时新消息在前
+type Result struct {
+	Hits  []*Hit `json:"hits"`
+	Total int64  `json:"total"` // 符合条件的结果总数
+}
+
+// Index 消息搜索索引
+type Index interface {
+	// Index 添加或更新消息
+	Index(ctx context.Context, message *model.Message) error
+	// Remove 移除对所有人删除的消息
+	Remove(ctx context.Context, messageID int64) error
+	// RemoveFor 移除用户仅对自己删除的消息，其他成员仍然可以搜索到
+	RemoveFor(ctx context.Context, userID, messageID int64) error
+	// Search 搜索消息
+	Search(ctx context.Context, query *Query) (*Result, error)
+}
+
+// Tokenize 把文本拆分为小写的搜索词
+// 字母和数字按单词拆分；汉字等没有空格分隔的文字按相邻两个字拆分，与 MySQL ngram 分词器（ngram_token_size=2）一致
+func Tokenize(text string) []string {
+	var tokens []string
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// isCJK 判断是否为不使用空格分词的文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Snippet 截取包含搜索词的片段并高亮匹配部分
+// 从第一个匹配处附近开始截取，没有匹配时返回开头部分
+func Snippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	if len(lower) != len(runes) {
+		// 个别字符转小写后长度变化，退化为不区分大小写地按原文匹配
+		lower = runes
+	}
+
+	// 标记每个字符是否属于某个匹配
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetLength/4 {
+		start = first - snippetLength/4
+	}
+	end := min(start+snippetLength, len(runes))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString(HighlightStart)
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString(HighlightEnd)
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// uniqueTerms 去除重复的搜索词，保持顺序
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,24 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"hello", "world", "42"}, Tokenize("Hello, World! 42"))
+	assert.Equal(t, []string{"今天", "天开", "开会", "at", "3"}, Tokenize("今天开会 at 3"))
+	assert.Equal(t, []string{"好"}, Tokenize("好"))
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t, "明天<mark>开会</mark>吗", Snippet("明天开会吗", Tokenize("开会")))
+	assert.Equal(t, "a &lt;b&gt; <mark>tag</mark>", Snippet("a <b> tag", []string{"tag"}))
+
+	long := "prefix " + strings.Repeat("x ", 100) + "needle"
+	snippet := Snippet(long, []string{"needle"})
+	assert.Contains(t, snippet, "<mark>needle</mark>")
+	assert.True(t, len(snippet) < len(long))
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,10 +14,43 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrNotChannel 操作只适用于频道
+	ErrNotChannel      = errors.New("chat is not a channel")
+	ErrInvalidChatName = errors.New("chat name cannot be empty")
+	ErrInvalidAvatar   = errors.New("avatar must be an image uploaded to this server")
+)
+
+// 服务消息的操作类型，对应 Message.Action
+const (
+	ActionChatRenamed        = "chat_renamed"
+	ActionChatPhotoChanged   = "chat_photo_changed"
+	ActionChatPhotoRemoved   = "chat_photo_removed"
+	ActionDescriptionChanged = "chat_description_changed"
+	ActionMemberLeft         = "member_left"
+	ActionOwnerChanged       = "owner_changed"
+)
+
+// ServiceMessageSender 向聊天室发送服务消息，由 MessageService 实现
+type ServiceMessageSender interface {
+	SendServiceMessage(ctx context.Context, chatID, actorID int64, action, content string) (*model.Message, error)
+}
+
+// MembershipListener 聊天室成员变更监听器
+// 用于让 WebSocket Hub 及时更新用户的聊天室订阅
+type MembershipListener interface {
+	OnMemberAdded(chatID, userID int64)
+	OnMemberRemoved(chatID, userID int64)
+}
+
 type ChatService struct {
-	chatRepo *repository.ChatRepository
-	userRepo *repository.UserRepository
-	logger   *zap.Logger
+	chatRepo           *repository.ChatRepository
+	userRepo           *repository.UserRepository
+	logger             *zap.Logger
+	membershipListener MembershipListener
+	events             EventBroadcaster
+	serviceMessages    ServiceMessageSender
+	fileService        *FileService
 }
 
 func NewChatService(
@@ -30,6 +65,40 @@ func NewChatService(
 	}
 }
 
+// SetMembershipListener 设置成员变更监听器
+func (s *ChatService) SetMembershipListener(listener MembershipListener) {
+	s.membershipListener = listener
+}
+
+// SetEventBroadcaster 设置实时事件广播器
+func (s *ChatService) SetEventBroadcaster(events EventBroadcaster) {
+	s.events = events
+}
+
+// SetServiceMessageSender 设置服务消息发送器，聊天室信息和成员变化时在聊天室中留下记录
+func (s *ChatService) SetServiceMessageSender(sender ServiceMessageSender) {
+	s.serviceMessages = sender
+}
+
+// SetFileService 设置文件服务，用于校验聊天室头像是本服务上传的图片
+func (s *ChatService) SetFileService(fileService *FileService) {
+	s.fileService = fileService
+}
+
+// notifyMemberAdded 通知成员加入
+func (s *ChatService) notifyMemberAdded(chatID, userID int64) {
+	if s.membershipListener != nil {
+		s.membershipListener.OnMemberAdded(chatID, userID)
+	}
+}
+
+// notifyMemberRemoved 通知成员移除
+func (s *ChatService) notifyMemberRemoved(chatID, userID int64) {
+	if s.membershipListener != nil {
+		s.membershipListener.OnMemberRemoved(chatID, userID)
+	}
+}
+
 type CreateChatRequest struct {
 	Name string `json:"name"`
 	Type int    `json:"type" validate:"required,min=1,max=3"` // 1: private, 2: group, 3: channel
@@ -51,26 +120,106 @@ func (s *ChatService) CreateChat(ctx context.Context, ownerID int64, req *Create
 	member := &model.ChatMember{
 		ChatID: chat.ID,
 		UserID: ownerID,
-		Role:   3, // owner
+		Role:   RoleOwner,
 	}
 	if err := s.chatRepo.AddMember(ctx, member); err != nil {
 		s.logger.Error("failed to add member", zap.Error(err))
+	} else {
+		s.notifyMemberAdded(chat.ID, ownerID)
 	}
 
 	return chat, nil
 }
 
-func (s *ChatService) GetChat(ctx context.Context, chatID int64) (*model.Chat, error) {
-	return s.chatRepo.FindByID(ctx, chatID)
+// ChatDetail 聊天室详情，包含当前的置顶消息
+type ChatDetail struct {
+	*model.Chat
+	PinnedMessages []*model.Message `json:"pinned_messages"` // 最近置顶的在前，仅成员可见
+}
+
+func (s *ChatService) GetChat(ctx context.Context, chatID, userID int64) (*ChatDetail, error) {
+	chat, err := s.chatRepo.FindByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 频道的成员列表对订阅者隐藏，只返回成员数
+	memberCount, err := s.chatRepo.CountMembers(ctx, chatID)
+	if err != nil {
+		s.logger.Error("failed to count chat members", zap.Error(err))
+		return nil, err
+	}
+	chat.MemberCount = int(memberCount)
+
+	detail := &ChatDetail{Chat: chat, PinnedMessages: []*model.Message{}}
+	if _, err := s.chatRepo.GetMember(ctx, chatID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return detail, nil
+		}
+		return nil, err
+	}
+
+	detail.PinnedMessages, err = s.chatRepo.GetPinnedMessages(ctx, chatID)
+	if err != nil {
+		s.logger.Error("failed to get pinned messages", zap.Error(err))
+		return nil, err
+	}
+
+	counts, err := s.chatRepo.CountUnread(ctx, userID, []int64{chatID})
+	if err != nil {
+		s.logger.Error("failed to count unread messages", zap.Error(err))
+		return nil, err
+	}
+	if len(counts) > 0 {
+		chat.UnreadCount = counts[0].Count
+	}
+	return detail, nil
 }
 
+// GetUserChats 获取用户的聊天列表，并填充每个聊天室的未读消息数
 func (s *ChatService) GetUserChats(ctx context.Context, userID int64) ([]*model.Chat, error) {
-	return s.chatRepo.GetUserChats(ctx, userID)
+	chats, err := s.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatIDs := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		chatIDs = append(chatIDs, chat.ID)
+	}
+	counts, err := s.chatRepo.CountUnread(ctx, userID, chatIDs)
+	if err != nil {
+		s.logger.Error("failed to count unread messages", zap.Error(err))
+		return nil, err
+	}
+
+	unread := make(map[int64]int, len(counts))
+	for _, count := range counts {
+		unread[count.ChatID] = count.Count
+	}
+	for _, chat := range chats {
+		chat.UnreadCount = unread[chat.ID]
+	}
+	return chats, nil
 }
 
-func (s *ChatService) AddMember(ctx context.Context, chatID, userID int64, role int) error {
+// AddMember 邀请用户加入群组或频道，需要邀请权限
+// 私聊没有邀请权限，只有创建者可以在对方加入前添加一个对方；用户已经是成员时不做任何修改
+func (s *ChatService) AddMember(ctx context.Context, chatID, actorID, userID int64) error {
+	chat, actor, err := chatMember(ctx, s.chatRepo, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if chat.Type == 1 {
+		if err := s.checkPrivatePeer(ctx, chat, actor, userID); err != nil {
+			return err
+		}
+	} else if !HasRight(chat, actor, RightInvite) {
+		return ErrNotAuthorized
+	}
+
 	// Check if user exists
-	_, err := s.userRepo.FindByID(ctx, userID)
+	_, err = s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrUserNotFound
@@ -78,20 +227,417 @@ func (s *ChatService) AddMember(ctx context.Context, chatID, userID int64, role
 		return err
 	}
 
+	_, err = s.joinChat(ctx, chat, userID, actorID)
+	return err
+}
+
+// checkPrivatePeer 校验 actor 能否把 userID 加入私聊：只有创建者可以添加，且私聊最多两个成员
+// 已经是成员的用户直接通过，由 joinChat 忽略
+func (s *ChatService) checkPrivatePeer(ctx context.Context, chat *model.Chat, actor *model.ChatMember, userID int64) error {
+	if actor.Role != RoleOwner {
+		return ErrNotAuthorized
+	}
+	count, err := s.chatRepo.CountMembers(ctx, chat.ID)
+	if err != nil {
+		return err
+	}
+	if count < 2 {
+		return nil
+	}
+	if _, err := s.chatRepo.GetMember(ctx, chat.ID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotAuthorized
+		}
+		return err
+	}
+	return nil
+}
+
+// MemberJoined member_joined 事件的内容
+type MemberJoined struct {
+	ChatID    int64 `json:"chat_id"`
+	UserID    int64 `json:"user_id"`
+	InviterID int64 `json:"inviter_id,omitempty"` // 添加成员或批准申请的管理员，通过链接直接加入时为空
+}
+
+// joinChat 把用户加入聊天室并通知在线成员，用户已经是成员时不做任何修改，被封禁时返回 ErrUserBanned
+// 返回是否新加入；频道的成员对订阅者隐藏，不推送加入事件
+func (s *ChatService) joinChat(ctx context.Context, chat *model.Chat, userID, inviterID int64) (bool, error) {
+	if _, err := s.chatRepo.GetMember(ctx, chat.ID, userID); err == nil {
+		return false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err := checkBanned(ctx, s.chatRepo, chat.ID, userID); err != nil {
+		return false, err
+	}
+
 	member := &model.ChatMember{
-		ChatID:   chatID,
+		ChatID:   chat.ID,
 		UserID:   userID,
-		Role:     role,
+		Role:     RoleMember,
 		JoinedAt: time.Now(),
 	}
-	return s.chatRepo.AddMember(ctx, member)
+	if err := s.chatRepo.AddMember(ctx, member); err != nil {
+		s.logger.Error("failed to add member", zap.Error(err))
+		return false, err
+	}
+
+	s.notifyMemberAdded(chat.ID, userID)
+	if chat.Type != 3 && s.events != nil {
+		s.events.OnChatEvent(chat.ID, EventMemberJoined, &MemberJoined{ChatID: chat.ID, UserID: userID, InviterID: inviterID})
+	}
+	return true, nil
+}
+
+// RemoveMember 移除成员，需要移除权限且只能移除角色比自己低的成员
+// 成员移除自己时等同于 LeaveChat
+func (s *ChatService) RemoveMember(ctx context.Context, chatID, actorID, userID int64) error {
+	if actorID == userID {
+		return s.LeaveChat(ctx, chatID, userID)
+	}
+
+	chat, actor, err := chatMember(ctx, s.chatRepo, chatID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := s.chatRepo.GetMember(ctx, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if !canManageMember(chat, actor, target, RightKick) {
+		return ErrNotAuthorized
+	}
+
+	if err := s.chatRepo.RemoveMember(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	s.notifyMemberRemoved(chatID, userID)
+	return nil
 }
 
-func (s *ChatService) RemoveMember(ctx context.Context, chatID, userID int64) error {
-	return s.chatRepo.RemoveMember(ctx, chatID, userID)
+// SetAdminRights 任命管理员或修改管理员的权限，rights 为 0 时撤销管理员
+// 需要管理管理员的权限，且只能授予自己拥有的权限
+func (s *ChatService) SetAdminRights(ctx context.Context, chatID, actorID, userID int64, rights Right) (*model.ChatMember, error) {
+	if rights&^RightsAll != 0 {
+		return nil, ErrInvalidRights
+	}
+
+	chat, actor, err := chatMember(ctx, s.chatRepo, chatID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.chatRepo.GetMember(ctx, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if !canManageMember(chat, actor, target, RightManageAdmins) || rights&^memberRights(chat, actor) != 0 {
+		return nil, ErrNotAuthorized
+	}
+
+	target.Role = RoleAdmin
+	if rights == 0 {
+		target.Role = RoleMember
+	}
+	target.Permissions = int64(rights)
+	if err := s.chatRepo.UpdateMember(ctx, target); err != nil {
+		s.logger.Error("failed to update member rights", zap.Error(err))
+		return nil, err
+	}
+	return target, nil
 }
 
-func (s *ChatService) GetMembers(ctx context.Context, chatID int64) ([]*model.ChatMember, error) {
+// Subscribe 订阅频道，任何用户都可以自由加入；已订阅时不做任何修改
+func (s *ChatService) Subscribe(ctx context.Context, chatID, userID int64) error {
+	chat, err := s.chatRepo.FindByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatNotFound
+		}
+		return err
+	}
+	if chat.Type != 3 {
+		return ErrNotChannel
+	}
+
+	_, err = s.joinChat(ctx, chat, userID, 0)
+	return err
+}
+
+// Unsubscribe 退订频道，所有者退订时转让所有权，见 LeaveChat
+func (s *ChatService) Unsubscribe(ctx context.Context, chatID, userID int64) error {
+	chat, err := s.chatRepo.FindByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatNotFound
+		}
+		return err
+	}
+	if chat.Type != 3 {
+		return ErrNotChannel
+	}
+	return s.RemoveMember(ctx, chatID, userID, userID)
+}
+
+// SetSignMessages 设置频道消息是否显示发布者签名，只影响之后发布的消息
+func (s *ChatService) SetSignMessages(ctx context.Context, chatID, userID int64, enabled bool) (*model.Chat, error) {
+	chat, err := s.settingsChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type != 3 {
+		return nil, ErrNotChannel
+	}
+
+	chat.SignMessages = enabled
+	if err := s.chatRepo.Update(ctx, chat); err != nil {
+		s.logger.Error("failed to update channel signatures", zap.Error(err))
+		return nil, err
+	}
+	return chat, nil
+}
+
+// SetAvailableReactions 设置聊天室允许使用的表情回应，为空表示不限制
+// 私聊双方都可以设置，群组和频道需要管理员或所有者
+func (s *ChatService) SetAvailableReactions(ctx context.Context, chatID, userID int64, reactions []string) (*model.Chat, error) {
+	chat, err := s.settingsChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat.AvailableReactions = reactions
+	if err := s.chatRepo.Update(ctx, chat); err != nil {
+		s.logger.Error("failed to update chat reactions", zap.Error(err))
+		return nil, err
+	}
+	return chat, nil
+}
+
+// SetMessageTTL 设置聊天室新消息的自动删除时间（秒），0 表示关闭
+// 只影响之后发送的消息，权限与 SetAvailableReactions 相同
+func (s *ChatService) SetMessageTTL(ctx context.Context, chatID, userID int64, ttl int) (*model.Chat, error) {
+	chat, err := s.settingsChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat.MessageTTL = ttl
+	if err := s.chatRepo.Update(ctx, chat); err != nil {
+		s.logger.Error("failed to update chat message ttl", zap.Error(err))
+		return nil, err
+	}
+	return chat, nil
+}
+
+// settingsChat 查询聊天室并校验用户能否修改其设置
+// 私聊双方都可以，群组和频道需要修改设置的权限
+func (s *ChatService) settingsChat(ctx context.Context, chatID, userID int64) (*model.Chat, error) {
+	chat, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightEditInfo)
+	return chat, err
+}
+
+// UpdateChatInfoRequest 修改聊天室信息请求，为空的字段不修改
+type UpdateChatInfoRequest struct {
+	Name        *string
+	Avatar      *string // 本服务上传的图片URL，空字符串表示移除头像
+	Description *string
+}
+
+// UpdateChatInfo 修改群组或频道的名称、头像和简介，需要修改设置的权限
+// 每项修改都会在聊天室中发送一条服务消息，并推送 chat_updated 事件
+func (s *ChatService) UpdateChatInfo(ctx context.Context, chatID, userID int64, req *UpdateChatInfoRequest) (*model.Chat, error) {
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return nil, ErrInvalidChatName
+	}
+	if req.Avatar != nil && *req.Avatar != "" && (s.fileService == nil || !s.fileService.IsUploadedImage(*req.Avatar)) {
+		return nil, ErrInvalidAvatar
+	}
+
+	chat, err := s.settingsChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type == 1 { // 私聊没有名称和头像
+		return nil, ErrNotAuthorized
+	}
+
+	actor := s.actorName(ctx, userID)
+	noun := chatNoun(chat)
+	type change struct{ action, content string }
+	var changes []change
+	if req.Name != nil && *req.Name != chat.Name {
+		chat.Name = *req.Name
+		changes = append(changes, change{ActionChatRenamed, fmt.Sprintf("%s changed the %s name to \"%s\"", actor, noun, chat.Name)})
+	}
+	if req.Avatar != nil && *req.Avatar != chat.Avatar {
+		chat.Avatar = *req.Avatar
+		if chat.Avatar == "" {
+			changes = append(changes, change{ActionChatPhotoRemoved, fmt.Sprintf("%s removed the %s photo", actor, noun)})
+		} else {
+			changes = append(changes, change{ActionChatPhotoChanged, fmt.Sprintf("%s changed the %s photo", actor, noun)})
+		}
+	}
+	if req.Description != nil && *req.Description != chat.Description {
+		chat.Description = *req.Description
+		changes = append(changes, change{ActionDescriptionChanged, fmt.Sprintf("%s changed the %s description", actor, noun)})
+	}
+	if len(changes) == 0 {
+		return chat, nil
+	}
+
+	if err := s.chatRepo.Update(ctx, chat); err != nil {
+		s.logger.Error("failed to update chat info", zap.Error(err))
+		return nil, err
+	}
+
+	for _, c := range changes {
+		s.sendServiceMessage(ctx, chat.ID, userID, c.action, c.content)
+	}
+	s.emitChatEvent(chat.ID, EventChatUpdated, chat)
+	return chat, nil
+}
+
+// MemberLeft member_left 事件的内容
+type MemberLeft struct {
+	ChatID     int64 `json:"chat_id"`
+	UserID     int64 `json:"user_id"`
+	NewOwnerID int64 `json:"new_owner_id,omitempty"` // 所有者退出时接任的成员
+}
+
+// LeaveChat 退出群组或频道，私聊不能退出
+// 所有者退出时所有权转让给最早任命的管理员，没有管理员时转让给最早加入的成员；最后一个成员退出时删除聊天室
+// 频道订阅者退出不发送服务消息和事件，与加入时一致
+func (s *ChatService) LeaveChat(ctx context.Context, chatID, userID int64) error {
+	chat, member, err := chatMember(ctx, s.chatRepo, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if chat.Type == 1 {
+		return ErrNotAuthorized
+	}
+
+	var successor *model.ChatMember
+	if member.Role == RoleOwner {
+		successor, err = s.chatRepo.FindSuccessor(ctx, chatID, userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.deleteChat(ctx, chat, []*model.ChatMember{member})
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.chatRepo.TransferOwnership(ctx, chatID, userID, successor.UserID); err != nil {
+			s.logger.Error("failed to transfer chat ownership", zap.Error(err))
+			return err
+		}
+	}
+
+	if err := s.chatRepo.RemoveMember(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	// 成员的订阅在推送之后移除，退出的用户的其他设备也能收到
+	event := &MemberLeft{ChatID: chatID, UserID: userID}
+	if chat.Type != 3 {
+		s.sendServiceMessage(ctx, chatID, userID, ActionMemberLeft, fmt.Sprintf("%s left the %s", s.actorName(ctx, userID), chatNoun(chat)))
+	}
+	if successor != nil {
+		event.NewOwnerID = successor.UserID
+		s.sendServiceMessage(ctx, chatID, userID, ActionOwnerChanged, fmt.Sprintf("%s is now the %s owner", s.actorName(ctx, successor.UserID), chatNoun(chat)))
+	}
+	if chat.Type != 3 || successor != nil {
+		s.emitChatEvent(chatID, EventMemberLeft, event)
+	}
+	s.notifyMemberRemoved(chatID, userID)
+	return nil
+}
+
+// ChatDeleted chat_deleted 事件的内容
+type ChatDeleted struct {
+	ChatID int64 `json:"chat_id"`
+}
+
+// DeleteChat 删除聊天室，只有所有者可以删除；所有成员被移出，消息不再可见
+func (s *ChatService) DeleteChat(ctx context.Context, chatID, userID int64) error {
+	chat, member, err := chatMember(ctx, s.chatRepo, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role != RoleOwner {
+		return ErrNotAuthorized
+	}
+
+	members, err := s.chatRepo.GetMembers(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	return s.deleteChat(ctx, chat, members)
+}
+
+// deleteChat 删除聊天室，通知成员后再移除他们的订阅
+func (s *ChatService) deleteChat(ctx context.Context, chat *model.Chat, members []*model.ChatMember) error {
+	if err := s.chatRepo.Delete(ctx, chat.ID); err != nil {
+		s.logger.Error("failed to delete chat", zap.Error(err))
+		return err
+	}
+
+	s.emitChatEvent(chat.ID, EventChatDeleted, &ChatDeleted{ChatID: chat.ID})
+	for _, member := range members {
+		s.notifyMemberRemoved(chat.ID, member.UserID)
+	}
+	return nil
+}
+
+// sendServiceMessage 发送服务消息，失败只记录日志，不影响已经完成的操作
+func (s *ChatService) sendServiceMessage(ctx context.Context, chatID, actorID int64, action, content string) {
+	if s.serviceMessages == nil {
+		return
+	}
+	if _, err := s.serviceMessages.SendServiceMessage(ctx, chatID, actorID, action, content); err != nil {
+		s.logger.Error("failed to send service message", zap.String("action", action), zap.Error(err))
+	}
+}
+
+// emitChatEvent 推送聊天室事件，未设置广播器时忽略
+func (s *ChatService) emitChatEvent(chatID int64, eventType string, payload interface{}) {
+	if s.events != nil {
+		s.events.OnChatEvent(chatID, eventType, payload)
+	}
+}
+
+// actorName 服务消息中用户的显示名称
+func (s *ChatService) actorName(ctx context.Context, userID int64) string {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get user for service message", zap.Error(err))
+		return "Someone"
+	}
+	return displayName(user)
+}
+
+// chatNoun 服务消息中对聊天室的称呼
+func chatNoun(chat *model.Chat) string {
+	if chat.Type == 3 {
+		return "channel"
+	}
+	return "group"
+}
+
+// GetMembers 获取聊天室成员列表，仅成员可见；频道的成员列表只对管理员和所有者可见
+func (s *ChatService) GetMembers(ctx context.Context, chatID, userID int64) ([]*model.ChatMember, error) {
+	chat, member, err := chatMember(ctx, s.chatRepo, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type == 3 && member.Role < RoleAdmin {
+		return nil, ErrNotAuthorized
+	}
 	return s.chatRepo.GetMembers(ctx, chatID)
 }
 
@@ -126,8 +672,10 @@ func (s *ChatService) GetOrCreatePrivateChat(ctx context.Context, userID1, userI
 	}
 
 	// Add both users as members
-	s.chatRepo.AddMember(ctx, &model.ChatMember{ChatID: chat.ID, UserID: userID1, Role: 3})
-	s.chatRepo.AddMember(ctx, &model.ChatMember{ChatID: chat.ID, UserID: userID2, Role: 1})
+	s.chatRepo.AddMember(ctx, &model.ChatMember{ChatID: chat.ID, UserID: userID1, Role: RoleOwner})
+	s.chatRepo.AddMember(ctx, &model.ChatMember{ChatID: chat.ID, UserID: userID2, Role: RoleMember})
+	s.notifyMemberAdded(chat.ID, userID1)
+	s.notifyMemberAdded(chat.ID, userID2)
 
 	return chat, nil
 }
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+)
+
+// testChatID 假数据库中聊天室的ID，用户ID从 11 开始，避免与 LIMIT 等参数混淆
+const testChatID = 100
+
+// memberQuery 查询单个成员的 SQL 片段
+const memberQuery = "FROM `chat_members` WHERE chat_id = ? AND user_id = ?"
+
+// testMember 创建 testChatID 中的成员
+func testMember(userID int64, role int, rights Right) *model.ChatMember {
+	return &model.ChatMember{ChatID: testChatID, UserID: userID, Role: role, Permissions: int64(rights)}
+}
+
+// newChatFixture 创建只包含一个聊天室及其成员的假数据库，其他用户都存在但不是成员
+func newChatFixture(t *testing.T, chatType int, members ...*model.ChatMember) (*testutil.FakeDB, *gorm.DB) {
+	fake, db := testutil.NewFakeDB(t)
+	fake.On("SELECT count(*) FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"count"},
+		Values:  [][]driver.Value{{int64(len(members))}},
+	})
+	fake.On("FROM `chats`", &testutil.Rows{
+		Columns: []string{"id", "type", "owner_id"},
+		Values:  [][]driver.Value{{int64(testChatID), int64(chatType), int64(11)}},
+	})
+
+	memberColumns := []string{"id", "chat_id", "user_id", "role", "permissions"}
+	all := &testutil.Rows{Columns: memberColumns}
+	for i, member := range members {
+		row := []driver.Value{int64(i + 1), member.ChatID, member.UserID, int64(member.Role), member.Permissions}
+		fake.OnArg(memberQuery, member.UserID, &testutil.Rows{Columns: memberColumns, Values: [][]driver.Value{row}})
+		all.Values = append(all.Values, row)
+	}
+	fake.On(memberQuery, &testutil.Rows{}) // 不是成员
+	fake.On("FROM `chat_members`", all)
+
+	fake.On("FROM `users`", &testutil.Rows{
+		Columns: []string{"id", "username"},
+		Values:  [][]driver.Value{{int64(20), "someone"}},
+	})
+	return fake, db
+}
+
+func newTestChatService(db *gorm.DB) *ChatService {
+	return NewChatService(repository.NewChatRepository(db), repository.NewUserRepository(db), zap.NewNop())
+}
+
+func TestChatService_AddMember(t *testing.T) {
+	owner := testMember(11, RoleOwner, 0)
+	inviter := testMember(12, RoleAdmin, RightInvite)
+	pinner := testMember(13, RoleAdmin, RightPin)
+	member := testMember(14, RoleMember, 0)
+	peer := testMember(15, RoleMember, 0)
+
+	tests := []struct {
+		name     string
+		chatType int
+		members  []*model.ChatMember
+		actorID  int64
+		userID   int64
+		err      error
+		added    bool
+	}{
+		{"private owner adds the peer", 1, []*model.ChatMember{owner}, 11, 20, nil, true},
+		{"private owner re-adds the peer", 1, []*model.ChatMember{owner, peer}, 11, 15, nil, false},
+		{"private chat is full", 1, []*model.ChatMember{owner, peer}, 11, 20, ErrNotAuthorized, false},
+		{"private peer cannot add", 1, []*model.ChatMember{owner, peer}, 15, 20, ErrNotAuthorized, false},
+		{"group owner adds", 2, []*model.ChatMember{owner}, 11, 20, nil, true},
+		{"group admin with invite right adds", 2, []*model.ChatMember{owner, inviter}, 12, 20, nil, true},
+		{"group admin without invite right", 2, []*model.ChatMember{owner, pinner}, 13, 20, ErrNotAuthorized, false},
+		{"group member cannot add", 2, []*model.ChatMember{owner, member}, 14, 20, ErrNotAuthorized, false},
+		{"non-member cannot add", 2, []*model.ChatMember{owner}, 30, 20, ErrNotAuthorized, false},
+		{"channel subscriber cannot add", 3, []*model.ChatMember{owner, member}, 14, 20, ErrNotAuthorized, false},
+		{"channel admin with invite right adds", 3, []*model.ChatMember{owner, inviter}, 12, 20, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := newChatFixture(t, tt.chatType, tt.members...)
+			s := newTestChatService(db)
+
+			err := s.AddMember(context.Background(), testChatID, tt.actorID, tt.userID)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.added, len(fake.Executed("INSERT INTO `chat_members`")) == 1)
+		})
+	}
+}
+
+func TestChatService_RemoveMember(t *testing.T) {
+	owner := testMember(11, RoleOwner, 0)
+	kicker := testMember(12, RoleAdmin, RightKick)
+	pinner := testMember(13, RoleAdmin, RightPin)
+	member := testMember(14, RoleMember, 0)
+	peer := testMember(15, RoleMember, 0)
+
+	tests := []struct {
+		name     string
+		chatType int
+		members  []*model.ChatMember
+		actorID  int64
+		userID   int64
+		err      error
+	}{
+		{"group owner removes member", 2, []*model.ChatMember{owner, member}, 11, 14, nil},
+		{"group owner removes admin", 2, []*model.ChatMember{owner, kicker}, 11, 12, nil},
+		{"admin with kick right removes member", 2, []*model.ChatMember{owner, kicker, member}, 12, 14, nil},
+		{"admin without kick right", 2, []*model.ChatMember{owner, pinner, member}, 13, 14, ErrNotAuthorized},
+		{"admin cannot remove another admin", 2, []*model.ChatMember{owner, kicker, pinner}, 12, 13, ErrNotAuthorized},
+		{"admin cannot remove owner", 2, []*model.ChatMember{owner, kicker}, 12, 11, ErrNotAuthorized},
+		{"member cannot remove member", 2, []*model.ChatMember{owner, member, peer}, 14, 15, ErrNotAuthorized},
+		{"target is not a member", 2, []*model.ChatMember{owner}, 11, 20, ErrUserNotFound},
+		{"non-member cannot remove", 2, []*model.ChatMember{owner, member}, 30, 14, ErrNotAuthorized},
+		{"private owner cannot remove peer", 1, []*model.ChatMember{owner, peer}, 11, 15, ErrNotAuthorized},
+		{"channel admin removes subscriber", 3, []*model.ChatMember{owner, kicker, member}, 12, 14, nil},
+		{"channel subscriber cannot remove", 3, []*model.ChatMember{owner, member, peer}, 14, 15, ErrNotAuthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := newChatFixture(t, tt.chatType, tt.members...)
+			s := newTestChatService(db)
+
+			err := s.RemoveMember(context.Background(), testChatID, tt.actorID, tt.userID)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.err == nil, len(fake.Executed("DELETE FROM `chat_members`")) == 1)
+		})
+	}
+}
+
+func TestChatService_SetAdminRights(t *testing.T) {
+	owner := testMember(11, RoleOwner, 0)
+	manager := testMember(12, RoleAdmin, RightManageAdmins|RightPin)
+	pinner := testMember(13, RoleAdmin, RightPin)
+	member := testMember(14, RoleMember, 0)
+	peer := testMember(15, RoleMember, 0)
+
+	tests := []struct {
+		name     string
+		chatType int
+		members  []*model.ChatMember
+		actorID  int64
+		userID   int64
+		rights   Right
+		err      error
+	}{
+		{"group owner promotes member", 2, []*model.ChatMember{owner, member}, 11, 14, DefaultAdminRights, nil},
+		{"group owner demotes admin", 2, []*model.ChatMember{owner, pinner}, 11, 13, 0, nil},
+		{"admin grants a right it has", 2, []*model.ChatMember{owner, manager, member}, 12, 14, RightPin, nil},
+		{"admin grants a right it lacks", 2, []*model.ChatMember{owner, manager, member}, 12, 14, RightKick, ErrNotAuthorized},
+		{"admin cannot change another admin", 2, []*model.ChatMember{owner, manager, pinner}, 12, 13, RightPin, ErrNotAuthorized},
+		{"admin without manage right", 2, []*model.ChatMember{owner, pinner, member}, 13, 14, RightPin, ErrNotAuthorized},
+		{"member cannot promote", 2, []*model.ChatMember{owner, member, peer}, 14, 15, RightPin, ErrNotAuthorized},
+		{"target is not a member", 2, []*model.ChatMember{owner}, 11, 20, RightPin, ErrUserNotFound},
+		{"unknown right bits", 2, []*model.ChatMember{owner, member}, 11, 14, RightsAll + 1, ErrInvalidRights},
+		{"private chat has no admins", 1, []*model.ChatMember{owner, peer}, 11, 15, RightPin, ErrNotAuthorized},
+		{"channel owner promotes subscriber", 3, []*model.ChatMember{owner, member}, 11, 14, RightPostMessages, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := newChatFixture(t, tt.chatType, tt.members...)
+			s := newTestChatService(db)
+
+			_, err := s.SetAdminRights(context.Background(), testChatID, tt.actorID, tt.userID, tt.rights)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.err == nil, len(fake.Executed("UPDATE `chat_members`")) == 1)
+		})
+	}
+}
+
+func TestChatService_GetMembers(t *testing.T) {
+	owner := testMember(11, RoleOwner, 0)
+	admin := testMember(12, RoleAdmin, RightPin)
+	member := testMember(14, RoleMember, 0)
+
+	tests := []struct {
+		name     string
+		chatType int
+		userID   int64
+		err      error
+	}{
+		{"private member", 1, 14, nil},
+		{"group member", 2, 14, nil},
+		{"group non-member", 2, 30, ErrNotAuthorized},
+		{"channel subscriber", 3, 14, ErrNotAuthorized},
+		{"channel admin", 3, 12, nil},
+		{"channel owner", 3, 11, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, db := newChatFixture(t, tt.chatType, owner, admin, member)
+			s := newTestChatService(db)
+
+			members, err := s.GetMembers(context.Background(), testChatID, tt.userID)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Len(t, members, 3)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+)
+
+// ErrorCode 把业务错误映射为错误码和提示信息
+// REST 接口和 WebSocket 回复使用同一套错误码，未知错误返回 500 和原始错误信息
+func ErrorCode(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrChatNotFound):
+		return 404, "Chat not found"
+	case errors.Is(err, ErrMessageNotFound):
+		return 404, "Message not found"
+	case errors.Is(err, ErrUserNotFound):
+		return 404, "User not found"
+	case errors.Is(err, ErrNotAuthorized):
+		return 403, "Not authorized"
+	case errors.Is(err, ErrInvalidRights):
+		return 400, "Invalid admin rights"
+	case errors.Is(err, ErrNotChannel):
+		return 400, "Chat is not a channel"
+	case errors.Is(err, ErrInvalidChatName):
+		return 400, "Chat name cannot be empty"
+	case errors.Is(err, ErrInvalidAvatar):
+		return 400, "Avatar must be an image uploaded to this server"
+	case errors.Is(err, ErrInviteNotFound):
+		return 404, "Invite link not found"
+	case errors.Is(err, ErrInviteExpired):
+		return 400, "Invite link is revoked, expired or used up"
+	case errors.Is(err, ErrInvalidInvite):
+		return 400, "Invalid invite link settings"
+	case errors.Is(err, ErrJoinRequestNotFound):
+		return 404, "Join request not found"
+	case errors.Is(err, ErrUserBanned):
+		return 403, "User is banned from this chat"
+	case errors.Is(err, ErrMuted):
+		return 403, "You are muted in this chat"
+	case errors.Is(err, ErrMediaRestricted):
+		return 403, "You cannot send media in this chat"
+	case errors.Is(err, ErrInvalidRestriction):
+		return 400, "Invalid restriction"
+	case errors.Is(err, ErrRestrictionNotFound):
+		return 404, "Restriction not found"
+	case errors.Is(err, ErrReactionNotAllowed):
+		return 400, "Reaction not allowed"
+	case errors.Is(err, ErrInvalidReply):
+		return 400, "Invalid reply target"
+	case errors.Is(err, ErrUnsupportedMessageType):
+		return 400, "Unsupported message type"
+	case errors.Is(err, ErrInvalidTTL):
+		return 400, "ttl must be between 0 and 604800 seconds"
+	case errors.Is(err, ErrInvalidClientMsgID):
+		return 400, "client_msg_id must be at most 64 characters"
+	case errors.Is(err, ErrReservedClientMsgID):
+		return 400, "client_msg_id must not start with sched-"
+	case errors.Is(err, ErrInvalidCursor):
+		return 400, "Only one of before_seq_id, after_seq_id and around_message_id can be set"
+	case errors.Is(err, ErrScheduledNotFound):
+		return 404, "Scheduled message not found"
+	case errors.Is(err, ErrInvalidSendAt):
+		return 400, "send_at must be in the future"
+	case errors.Is(err, ErrPollNotFound):
+		return 404, "Poll not found"
+	case errors.Is(err, ErrInvalidPoll):
+		return 400, "Invalid poll"
+	case errors.Is(err, ErrInvalidPollOption):
+		return 400, "Invalid poll option"
+	case errors.Is(err, ErrPollClosed):
+		return 400, "Poll is closed"
+	case errors.Is(err, ErrQuizAnswered):
+		return 400, "Quiz answer cannot be changed"
+	case errors.Is(err, ErrPollAnonymous):
+		return 403, "Poll voters are anonymous"
+	}
+	return 500, err.Error()
+}
@@ -0,0 +1,24 @@
+package service
+
+// 实时事件类型，作为 WebSocket 帧的 type 推送给客户端
+const (
+	EventMessageEdited   = "message_edited"   // 消息被编辑，payload 为编辑后的消息
+	EventMessageDeleted  = "message_deleted"  // 消息被删除，payload 为 Tombstone
+	EventReactionUpdated = "reaction_updated" // 消息的表情回应变化，payload 为 ReactionUpdate
+	EventMessagePinned   = "message_pinned"   // 消息被置顶或取消置顶，payload 为 PinUpdate
+	EventPollUpdated     = "poll_updated"     // 投票结果变化或投票结束，payload 为 PollUpdate
+	EventMemberJoined    = "member_joined"    // 新成员加入聊天室，payload 为 MemberJoined
+	EventJoinRequest     = "join_request"     // 加入申请被批准或拒绝，推送给申请人，payload 为 JoinRequestUpdate
+	EventMemberLeft      = "member_left"      // 成员退出聊天室，payload 为 MemberLeft
+	EventChatUpdated     = "chat_updated"     // 聊天室名称、头像或简介被修改，payload 为修改后的聊天室
+	EventChatDeleted     = "chat_deleted"     // 聊天室被所有者删除，payload 为 ChatDeleted
+)
+
+// EventBroadcaster 实时事件广播器
+// 消息编辑等状态变更通过它推送给在线客户端，离线设备通过 /api/sync 补齐
+type EventBroadcaster interface {
+	// OnChatEvent 推送事件给聊天室的所有成员
+	OnChatEvent(chatID int64, eventType string, payload interface{})
+	// OnUserEvent 推送事件给指定用户的所有设备
+	OnUserEvent(userID int64, eventType string, payload interface{})
+}
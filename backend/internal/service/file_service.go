@@ -319,6 +319,25 @@ func (s *FileService) GetFilePath(relativePath string) string {
 	return filepath.Join(s.uploadPath, relativePath)
 }
 
+// RelativePath 把访问URL还原为相对路径，不是本服务上传的文件时返回 false
+func (s *FileService) RelativePath(accessURL string) (string, bool) {
+	prefix := s.baseURL + "/"
+	if !strings.HasPrefix(accessURL, prefix) {
+		return "", false
+	}
+	relativePath := filepath.Clean(strings.TrimPrefix(accessURL, prefix))
+	if relativePath == "." || filepath.IsAbs(relativePath) || strings.HasPrefix(relativePath, "..") {
+		return "", false
+	}
+	return relativePath, true
+}
+
+// IsUploadedImage 判断访问URL是否为本服务上传的图片
+func (s *FileService) IsUploadedImage(accessURL string) bool {
+	relativePath, ok := s.RelativePath(accessURL)
+	return ok && strings.HasPrefix(filepath.ToSlash(relativePath), s.getSubDir(FileTypeImage)+"/")
+}
+
 // GetFileURL 获取文件的访问URL
 func (s *FileService) GetFileURL(relativePath string) string {
 	relativePath = strings.ReplaceAll(relativePath, "\\", "/")
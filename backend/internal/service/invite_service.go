@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInviteNotFound      = errors.New("invite link not found")
+	ErrInviteExpired       = errors.New("invite link is revoked, expired or used up")
+	ErrInvalidInvite       = errors.New("invalid invite link settings")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
+
+// inviteCodeLength 邀请码长度
+const inviteCodeLength = 16
+
+// InviteService 邀请链接和加入申请
+type InviteService struct {
+	inviteRepo  *repository.InviteRepository
+	chatRepo    *repository.ChatRepository
+	chatService *ChatService
+	logger      *zap.Logger
+}
+
+func NewInviteService(
+	inviteRepo *repository.InviteRepository,
+	chatRepo *repository.ChatRepository,
+	chatService *ChatService,
+	logger *zap.Logger,
+) *InviteService {
+	return &InviteService{
+		inviteRepo:  inviteRepo,
+		chatRepo:    chatRepo,
+		chatService: chatService,
+		logger:      logger,
+	}
+}
+
+// CreateInviteRequest 创建邀请链接请求
+type CreateInviteRequest struct {
+	Title            string     `json:"title"`
+	ExpiresAt        *time.Time `json:"expires_at"`        // 为空表示不过期
+	MaxUses          int        `json:"max_uses"`          // 0 表示不限次数
+	RequiresApproval bool       `json:"requires_approval"` // 加入需要管理员批准，不能同时限制次数
+}
+
+// CreateInvite 创建邀请链接，需要邀请权限
+func (s *InviteService) CreateInvite(ctx context.Context, chatID, userID int64, req *CreateInviteRequest) (*model.ChatInvite, error) {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightInvite); err != nil {
+		return nil, err
+	}
+	if req.RequiresApproval && req.MaxUses > 0 {
+		return nil, ErrInvalidInvite
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidInvite
+	}
+
+	invite := &model.ChatInvite{
+		ChatID:           chatID,
+		Code:             RandomString(inviteCodeLength),
+		CreatorID:        userID,
+		Title:            req.Title,
+		ExpiresAt:        req.ExpiresAt,
+		MaxUses:          req.MaxUses,
+		RequiresApproval: req.RequiresApproval,
+	}
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		s.logger.Error("failed to create invite link", zap.Error(err))
+		return nil, err
+	}
+	return invite, nil
+}
+
+// GetInvites 获取聊天室的邀请链接，包括已撤销和已过期的，需要邀请权限
+func (s *InviteService) GetInvites(ctx context.Context, chatID, userID int64) ([]*model.ChatInvite, error) {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightInvite); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.FindByChatID(ctx, chatID)
+}
+
+// RevokeInvite 撤销邀请链接，需要邀请权限；已撤销的链接再次撤销不做任何修改
+func (s *InviteService) RevokeInvite(ctx context.Context, chatID, inviteID, userID int64) (*model.ChatInvite, error) {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightInvite); err != nil {
+		return nil, err
+	}
+
+	invite, err := s.inviteRepo.FindByID(ctx, inviteID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	if invite.ChatID != chatID {
+		return nil, ErrInviteNotFound
+	}
+
+	now := time.Now()
+	revoked, err := s.inviteRepo.Revoke(ctx, invite.ID, now)
+	if err != nil {
+		s.logger.Error("failed to revoke invite link", zap.Error(err))
+		return nil, err
+	}
+	if revoked {
+		invite.RevokedAt = &now
+	}
+	return invite, nil
+}
+
+// JoinResult 通过邀请链接加入的结果
+type JoinResult struct {
+	Chat    *model.Chat `json:"chat"`
+	Pending bool        `json:"pending"` // 已提交加入申请，等待管理员批准
+}
+
+// JoinByCode 通过邀请链接加入聊天室
+// 需要批准的链接只提交加入申请；已经是成员时直接返回聊天室，不消耗使用次数
+func (s *InviteService) JoinByCode(ctx context.Context, userID int64, code string) (*JoinResult, error) {
+	invite, err := s.inviteRepo.FindByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+
+	chat, err := s.chatRepo.FindByID(ctx, invite.ChatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	result := &JoinResult{Chat: chat}
+
+	if _, err := s.chatRepo.GetMember(ctx, chat.ID, userID); err == nil {
+		return result, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 被封禁的用户不能提交申请，也不消耗使用次数
+	if err := checkBanned(ctx, s.chatRepo, chat.ID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !invite.IsUsable(now) {
+		return nil, ErrInviteExpired
+	}
+
+	if invite.RequiresApproval {
+		request := &model.ChatJoinRequest{
+			ChatID:   chat.ID,
+			UserID:   userID,
+			InviteID: invite.ID,
+		}
+		if err := s.inviteRepo.CreateJoinRequest(ctx, request); err != nil {
+			s.logger.Error("failed to create join request", zap.Error(err))
+			return nil, err
+		}
+		result.Pending = true
+		return result, nil
+	}
+
+	// 并发加入时以条件更新为准，保证不超过使用次数上限
+	used, err := s.inviteRepo.Use(ctx, invite.ID, now)
+	if err != nil {
+		s.logger.Error("failed to use invite link", zap.Error(err))
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInviteExpired
+	}
+
+	if _, err := s.chatService.joinChat(ctx, chat, userID, 0); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetJoinRequests 获取待处理的加入申请，需要邀请权限
+func (s *InviteService) GetJoinRequests(ctx context.Context, chatID, userID int64) ([]*model.ChatJoinRequest, error) {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightInvite); err != nil {
+		return nil, err
+	}
+	return s.inviteRepo.FindJoinRequests(ctx, chatID)
+}
+
+// JoinRequestUpdate join_request 事件的内容
+type JoinRequestUpdate struct {
+	ChatID   int64 `json:"chat_id"`
+	Approved bool  `json:"approved"`
+}
+
+// ApproveJoinRequest 批准加入申请，需要邀请权限
+func (s *InviteService) ApproveJoinRequest(ctx context.Context, chatID, actorID, userID int64) error {
+	return s.decideJoinRequest(ctx, chatID, actorID, userID, true)
+}
+
+// DenyJoinRequest 拒绝加入申请，需要邀请权限
+func (s *InviteService) DenyJoinRequest(ctx context.Context, chatID, actorID, userID int64) error {
+	return s.decideJoinRequest(ctx, chatID, actorID, userID, false)
+}
+
+// decideJoinRequest 处理加入申请并通知申请人
+// 先删除申请再加入，多个管理员同时处理时只有一个生效
+func (s *InviteService) decideJoinRequest(ctx context.Context, chatID, actorID, userID int64, approved bool) error {
+	chat, _, err := requireRight(ctx, s.chatRepo, chatID, actorID, RightInvite)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.inviteRepo.DeleteJoinRequest(ctx, chatID, userID)
+	if err != nil {
+		s.logger.Error("failed to delete join request", zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrJoinRequestNotFound
+	}
+
+	if approved {
+		if _, err := s.chatService.joinChat(ctx, chat, userID, actorID); err != nil {
+			return err
+		}
+	}
+
+	if s.chatService.events != nil {
+		s.chatService.events.OnUserEvent(userID, EventJoinRequest, &JoinRequestUpdate{ChatID: chatID, Approved: approved})
+	}
+	return nil
+}
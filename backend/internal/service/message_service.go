@@ -4,20 +4,40 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
 
 	"github.com/forever-free1/telegram-go/backend/internal/model"
 	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/search"
 	"github.com/forever-free1/telegram-go/backend/pkg/snowflake"
 	"go.uber.org/zap"
 )
 
 var (
-	ErrChatNotFound    = errors.New("chat not found")
-	ErrMessageNotFound = errors.New("message not found")
-	ErrNotAuthorized  = errors.New("not authorized to perform this action")
+	ErrChatNotFound           = errors.New("chat not found")
+	ErrMessageNotFound        = errors.New("message not found")
+	ErrNotAuthorized          = errors.New("not authorized to perform this action")
+	ErrReactionNotAllowed     = errors.New("reaction not allowed in this chat")
+	ErrInvalidReply           = errors.New("replied message is not in this chat or was deleted")
+	ErrInvalidCursor          = errors.New("only one of before_seq_id, after_seq_id and around_message_id can be set")
+	ErrUnsupportedMessageType = errors.New("unsupported message type")
+	ErrInvalidTTL             = errors.New("ttl must be between 0 and 604800 seconds")
+	ErrInvalidClientMsgID     = errors.New("client_msg_id must be at most 64 characters")
+	ErrReservedClientMsgID    = errors.New("client_msg_id must not start with sched-")
+)
+
+const (
+	// maxMessageTTL 阅后即焚的最长时间（秒），7 天
+	maxMessageTTL = 7 * 24 * 60 * 60
+	// maxClientMsgIDLength ClientMsgID 的最大长度，与数据库字段长度一致
+	maxClientMsgIDLength = 64
+	// scheduledClientMsgIDPrefix 定时消息发送时使用的 ClientMsgID 前缀，客户端不能使用，避免与定时消息的去重冲突
+	scheduledClientMsgIDPrefix = "sched-"
 )
 
 type UserClaims struct {
@@ -44,24 +64,29 @@ func (f MessageBroadcasterFunc) OnMessageSaved(message *model.Message) {
 }
 
 type MessageService struct {
-	messageRepo  *repository.MessageRepository
-	chatRepo     *repository.ChatRepository
-	userRepo     *repository.UserRepository
-	logger       *zap.Logger
-	broadcaster  MessageBroadcaster
+	messageRepo   *repository.MessageRepository
+	chatRepo      *repository.ChatRepository
+	pollRepo      *repository.PollRepository
+	userRepo      *repository.UserRepository
+	logger        *zap.Logger
+	broadcaster   MessageBroadcaster
 	broadcasterMu sync.RWMutex
-	pushService  PushService // 离线推送服务
+	pushService   PushService      // 离线推送服务
+	events        EventBroadcaster // 实时事件广播，如消息编辑
+	searchIndex   search.Index     // 消息搜索索引
 }
 
 func NewMessageService(
 	messageRepo *repository.MessageRepository,
 	chatRepo *repository.ChatRepository,
+	pollRepo *repository.PollRepository,
 	userRepo *repository.UserRepository,
 	logger *zap.Logger,
 ) *MessageService {
 	return &MessageService{
 		messageRepo: messageRepo,
 		chatRepo:    chatRepo,
+		pollRepo:    pollRepo,
 		userRepo:    userRepo,
 		logger:      logger,
 	}
@@ -79,6 +104,42 @@ func (s *MessageService) SetBroadcaster(broadcaster MessageBroadcaster) {
 	s.broadcaster = broadcaster
 }
 
+// SetEventBroadcaster 设置实时事件广播器
+func (s *MessageService) SetEventBroadcaster(events EventBroadcaster) {
+	s.events = events
+}
+
+// SetSearchIndex 设置消息搜索索引，消息发送、编辑和删除时同步更新
+func (s *MessageService) SetSearchIndex(index search.Index) {
+	s.searchIndex = index
+}
+
+// indexMessages 把新增或编辑后的消息写入搜索索引，失败只记录日志
+func (s *MessageService) indexMessages(ctx context.Context, messages ...*model.Message) {
+	if s.searchIndex == nil {
+		return
+	}
+	for _, message := range messages {
+		if err := s.searchIndex.Index(ctx, message); err != nil {
+			s.logger.Error("failed to index message", zap.Int64("message_id", message.ID), zap.Error(err))
+		}
+	}
+}
+
+// emitChatEvent 推送事件给聊天室成员
+func (s *MessageService) emitChatEvent(chatID int64, eventType string, payload interface{}) {
+	if s.events != nil {
+		s.events.OnChatEvent(chatID, eventType, payload)
+	}
+}
+
+// emitUserEvent 推送事件给用户的所有设备
+func (s *MessageService) emitUserEvent(userID int64, eventType string, payload interface{}) {
+	if s.events != nil {
+		s.events.OnUserEvent(userID, eventType, payload)
+	}
+}
+
 // broadcast 广播消息（线程安全）
 func (s *MessageService) broadcast(message *model.Message) {
 	s.broadcasterMu.RLock()
@@ -92,32 +153,124 @@ func (s *MessageService) broadcast(message *model.Message) {
 
 type SendMessageRequest struct {
 	ChatID    int64   `json:"chat_id" validate:"required"`
-	Type      int     `json:"type" validate:"required,min=1,max=5"`
+	Type      int     `json:"type" validate:"required,min=1,max=6"`
 	Content   string  `json:"content"`
 	MediaURL  string  `json:"media_url"`
 	Duration  int     `json:"duration"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	ReplyID   int64   `json:"reply_id"`
+	// ClientMsgID 客户端生成的随机ID，同一发送者重复提交时返回已有消息而不是重复创建
+	ClientMsgID string `json:"client_msg_id"`
+	// TTL 阅后即焚秒数，从首次被读开始计时，0 表示不自动删除，最长 maxMessageTTL
+	TTL int `json:"ttl"`
+	// Poll 投票内容，Type 为 6 时必填
+	Poll *PollRequest `json:"poll"`
+
+	// scheduled 由定时消息到期发送，只有这时 ClientMsgID 可以使用保留前缀
+	scheduled bool
 }
 
-func (s *MessageService) SendMessage(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Message, error) {
-	// Check if chat exists
-	chat, err := s.chatRepo.FindByID(ctx, req.ChatID)
+// checkSend 校验用户能否在聊天室发送该消息
+// 返回聊天室和被回复的消息（未回复时为空）
+func (s *MessageService) checkSend(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Chat, *model.Message, error) {
+	if req.Type == 7 { // 服务消息只能由服务端生成
+		return nil, nil, ErrNotAuthorized
+	}
+	// REST、WebSocket 和定时消息共用同一个范围
+	if req.TTL < 0 || req.TTL > maxMessageTTL {
+		return nil, nil, ErrInvalidTTL
+	}
+	if utf8.RuneCountInString(req.ClientMsgID) > maxClientMsgIDLength {
+		return nil, nil, ErrInvalidClientMsgID
+	}
+	if !req.scheduled && strings.HasPrefix(req.ClientMsgID, scheduledClientMsgIDPrefix) {
+		return nil, nil, ErrReservedClientMsgID
+	}
+
+	chat, err := s.postableChat(ctx, req.ChatID, senderID, req.Type)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrChatNotFound
+		return nil, nil, err
+	}
+
+	if req.Type == 6 { // poll
+		if err := validatePoll(req.Poll); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 被回复的消息必须在同一个聊天室且未删除
+	var replyTo *model.Message
+	if req.ReplyID != 0 {
+		replyTo, err = s.messageRepo.FindByID(ctx, req.ReplyID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, ErrInvalidReply
+			}
+			return nil, nil, err
 		}
+		if replyTo.ChatID != req.ChatID || replyTo.IsDeleted {
+			return nil, nil, ErrInvalidReply
+		}
+	}
+
+	return chat, replyTo, nil
+}
+
+// postableChat 查询聊天室并校验用户能否在其中发送指定类型的消息
+// 需要是聊天室成员且没有被禁言或限制发送媒体，频道中只有有发布权限的管理员可以发送
+func (s *MessageService) postableChat(ctx context.Context, chatID, userID int64, msgTypes ...int) (*model.Chat, error) {
+	chat, member, err := chatMember(ctx, s.chatRepo, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type == 3 && !HasRight(chat, member, RightPostMessages) {
+		return nil, ErrNotAuthorized
+	}
+	if err := checkRestricted(ctx, s.chatRepo, member, msgTypes...); err != nil {
 		return nil, err
 	}
+	return chat, nil
+}
 
-	// Check if user is a member of the chat
-	_, err = s.chatRepo.GetMember(ctx, req.ChatID, senderID)
+// signature 频道开启签名时返回发布者的昵称，否则返回空
+func (s *MessageService) signature(ctx context.Context, chat *model.Chat, senderID int64) string {
+	if chat.Type != 3 || !chat.SignMessages {
+		return ""
+	}
+	sender, err := s.userRepo.FindByID(ctx, senderID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotAuthorized
+		s.logger.Error("failed to get sender for signature", zap.Error(err))
+		return ""
+	}
+	return displayName(sender)
+}
+
+// displayName 用户的显示名称，未设置昵称时使用用户名
+func displayName(user *model.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
+
+// createMessage 校验权限并保存消息
+// created 为 false 表示命中了 ClientMsgID，返回的是之前已保存的消息
+func (s *MessageService) createMessage(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Message, *model.Chat, bool, error) {
+	chat, replyTo, err := s.checkSend(ctx, senderID, req)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// 重试请求直接返回已保存的消息
+	if req.ClientMsgID != "" {
+		existing, err := s.messageRepo.FindByClientMsgID(ctx, senderID, req.ClientMsgID)
+		if err == nil {
+			return existing, chat, false, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, false, err
 		}
-		return nil, err
 	}
 
 	// Create message
@@ -132,25 +285,258 @@ func (s *MessageService) SendMessage(ctx context.Context, senderID int64, req *S
 		Latitude:  req.Latitude,
 		Longitude: req.Longitude,
 		ReplyID:   req.ReplyID,
+		TTL:       req.TTL,
+		ExpiresAt: chatExpiresAt(chat),
+		Signature: s.signature(ctx, chat, senderID),
+	}
+	if req.ClientMsgID != "" {
+		message.ClientMsgID = &req.ClientMsgID
 	}
 
-	if err := s.messageRepo.Create(ctx, message); err != nil {
+	var poll *model.Poll
+	if req.Type == 6 { // poll，消息内容为投票问题，用于引用摘要和推送
+		poll = newPoll(req.Poll)
+		message.Content = poll.Question
+		message.MediaURL = ""
+	}
+
+	if err := s.createWithPoll(ctx, message, poll); err != nil {
+		// 并发重试时唯一索引冲突，返回先保存成功的那条
+		if req.ClientMsgID != "" {
+			if existing, findErr := s.messageRepo.FindByClientMsgID(ctx, senderID, req.ClientMsgID); findErr == nil {
+				return existing, chat, false, nil
+			}
+		}
 		s.logger.Error("failed to create message", zap.Error(err))
+		return nil, nil, false, err
+	}
+
+	if replyTo != nil {
+		if err := s.messageRepo.IncrementReplyCount(ctx, replyTo.ID, 1); err != nil {
+			s.logger.Error("failed to update reply count", zap.Error(err))
+		}
+		message.ReplyTo = newQuote(replyTo)
+	}
+	if poll != nil {
+		if err := s.fillPollResults(ctx, []*model.Poll{poll}, senderID); err != nil {
+			s.logger.Error("failed to get poll results", zap.Error(err))
+		}
+		message.Poll = poll
+	}
+	s.indexMessages(ctx, message)
+
+	return message, chat, true, nil
+}
+
+// createWithPoll 保存消息，投票消息同时保存投票
+func (s *MessageService) createWithPoll(ctx context.Context, message *model.Message, poll *model.Poll) error {
+	if poll == nil {
+		return s.messageRepo.Create(ctx, message)
+	}
+	return s.pollRepo.Create(ctx, message, poll)
+}
+
+func (s *MessageService) SendMessage(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Message, error) {
+	message, chat, created, err := s.createMessage(ctx, senderID, req)
+	if err != nil {
 		return nil, err
 	}
 
+	// 重复提交的消息已经广播和推送过
+	if !created {
+		return message, nil
+	}
+
 	// 消息保存成功后，触发 WebSocket 广播
 	s.broadcast(message)
 
-	// 发送离线推送（如果是私聊）
-	// 群聊的离线推送逻辑更复杂，这里先实现私聊
-	if chat.Type == 1 { // private chat
-		go s.sendOfflinePush(ctx, message, senderID)
+	// 发送离线推送
+	s.pushOffline(ctx, chat, message, senderID)
+
+	return message, nil
+}
+
+// SendServiceMessage 向聊天室发送服务消息，记录聊天室信息和成员的变化
+// 服务消息由服务端生成，actorID 为执行操作的用户；不能编辑和转发，也不发送离线推送
+func (s *MessageService) SendServiceMessage(ctx context.Context, chatID, actorID int64, action, content string) (*model.Message, error) {
+	message := &model.Message{
+		SeqID:    snowflake.GenerateID(),
+		ChatID:   chatID,
+		SenderID: actorID,
+		Type:     7,
+		Content:  content,
+		Action:   action,
+	}
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		s.logger.Error("failed to create service message", zap.Error(err))
+		return nil, err
 	}
 
+	s.broadcast(message)
 	return message, nil
 }
 
+// ForwardMessagesRequest 转发消息请求
+type ForwardMessagesRequest struct {
+	TargetChatID int64   `json:"target_chat_id"`
+	MessageIDs   []int64 `json:"message_ids"`
+}
+
+// ForwardMessages 把消息转发到目标聊天室
+// 需要同时是来源聊天室和目标聊天室的成员；转发的消息保留最初的来源，按原消息的先后顺序创建
+func (s *MessageService) ForwardMessages(ctx context.Context, senderID int64, req *ForwardMessagesRequest) ([]*model.Message, error) {
+	sources, err := s.messageRepo.FindByIDs(ctx, req.MessageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	msgTypes := make([]int, 0, len(sources))
+	for _, source := range sources {
+		msgTypes = append(msgTypes, source.Type)
+	}
+	chat, err := s.postableChat(ctx, req.TargetChatID, senderID, msgTypes...)
+	if err != nil {
+		return nil, err
+	}
+	signature := s.signature(ctx, chat, senderID)
+
+	// 每个来源聊天室只校验一次成员身份
+	checked := make(map[int64]bool)
+	messages := make([]*model.Message, 0, len(sources))
+	for _, source := range sources {
+		if source.IsDeleted {
+			return nil, ErrMessageNotFound
+		}
+		if source.Type == 7 { // 服务消息不能转发
+			return nil, ErrNotAuthorized
+		}
+		if !checked[source.ChatID] {
+			if _, err := s.chatRepo.GetMember(ctx, source.ChatID, senderID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, ErrNotAuthorized
+				}
+				return nil, err
+			}
+			checked[source.ChatID] = true
+		}
+
+		message := &model.Message{
+			SeqID:                  snowflake.GenerateID(),
+			ChatID:                 req.TargetChatID,
+			SenderID:               senderID,
+			Type:                   source.Type,
+			Content:                source.Content,
+			MediaURL:               source.MediaURL,
+			Duration:               source.Duration,
+			Latitude:               source.Latitude,
+			Longitude:              source.Longitude,
+			ForwardedFromChatID:    source.ChatID,
+			ForwardedFromMessageID: source.ID,
+			ForwardedFromSenderID:  source.SenderID,
+			Signature:              signature,
+			ExpiresAt:              chatExpiresAt(chat),
+		}
+		// 转发已转发过的消息时保留最初的来源
+		if source.ForwardedFromMessageID != 0 {
+			message.ForwardedFromChatID = source.ForwardedFromChatID
+			message.ForwardedFromMessageID = source.ForwardedFromMessageID
+			message.ForwardedFromSenderID = source.ForwardedFromSenderID
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) != len(req.MessageIDs) {
+		return nil, ErrMessageNotFound
+	}
+
+	if err := s.messageRepo.CreateBatch(ctx, messages); err != nil {
+		s.logger.Error("failed to forward messages", zap.Error(err))
+		return nil, err
+	}
+	s.indexMessages(ctx, messages...)
+	// 转发的投票与原消息共用同一个投票
+	if err := s.fillPolls(ctx, messages, senderID); err != nil {
+		s.logger.Error("failed to get polls", zap.Error(err))
+	}
+
+	for _, message := range messages {
+		s.broadcast(message)
+		s.pushOffline(ctx, chat, message, senderID)
+	}
+
+	return messages, nil
+}
+
+// chatExpiresAt 根据聊天室的自动删除设置计算新消息的到期时间，未设置时返回空
+func chatExpiresAt(chat *model.Chat) *time.Time {
+	if chat.MessageTTL <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(chat.MessageTTL) * time.Second)
+	return &expiresAt
+}
+
+// channelPushBatchSize 频道离线推送每批的订阅者数
+const channelPushBatchSize = 500
+
+// pushOffline 异步发送离线推送
+// 私聊逐个推送；频道按批推送给所有订阅者；群聊暂不推送
+func (s *MessageService) pushOffline(ctx context.Context, chat *model.Chat, message *model.Message, senderID int64) {
+	switch chat.Type {
+	case 1: // private chat
+		go s.sendOfflinePush(ctx, message, senderID)
+	case 3: // channel
+		go s.sendChannelPush(context.WithoutCancel(ctx), chat, message)
+	}
+}
+
+// sendChannelPush 分批推送频道消息给离线的订阅者，推送标题为频道名称
+func (s *MessageService) sendChannelPush(ctx context.Context, chat *model.Chat, message *model.Message) {
+	if s.pushService == nil {
+		return
+	}
+
+	content := pushText(message)
+	if message.Signature != "" {
+		content = message.Signature + ": " + content
+	}
+	data := map[string]string{
+		"chat_id":    strconv.FormatInt(message.ChatID, 10),
+		"message_id": strconv.FormatInt(message.ID, 10),
+	}
+
+	var afterUserID int64
+	for {
+		userIDs, err := s.chatRepo.GetMemberIDsAfter(ctx, chat.ID, afterUserID, channelPushBatchSize)
+		if err != nil {
+			s.logger.Error("failed to get channel subscribers for offline push", zap.Error(err))
+			return
+		}
+		if len(userIDs) == 0 {
+			return
+		}
+
+		offline := make([]int64, 0, len(userIDs))
+		for _, userID := range userIDs {
+			if userID != message.SenderID && !s.pushService.IsUserOnline(userID) {
+				offline = append(offline, userID)
+			}
+		}
+		if len(offline) > 0 {
+			if err := s.pushService.PushBatch(ctx, offline, chat.Name, content, data); err != nil {
+				s.logger.Error("failed to send channel push",
+					zap.Int64("chat_id", chat.ID),
+					zap.Int("recipients", len(offline)),
+					zap.Error(err))
+			}
+		}
+
+		if len(userIDs) < channelPushBatchSize {
+			return
+		}
+		afterUserID = userIDs[len(userIDs)-1]
+	}
+}
+
 // sendOfflinePush 发送离线推送
 // 检查聊天室成员是否在线，不在线则发送推送
 func (s *MessageService) sendOfflinePush(ctx context.Context, message *model.Message, senderID int64) {
@@ -180,29 +566,8 @@ func (s *MessageService) sendOfflinePush(ctx context.Context, message *model.Mes
 	}
 
 	// 构建推送内容
-	title := sender.Nickname
-	if title == "" {
-		title = sender.Username
-	}
-
-	var content string
-	switch message.Type {
-	case 1: // text
-		content = message.Content
-	case 2: // image
-		content = "[图片]"
-	case 3: // file
-		content = "[文件]"
-	case 4: // voice
-		content = "[语音]"
-	case 5: // location
-		content = "[位置]"
-	}
-
-	// 限制内容长度
-	if len(content) > 100 {
-		content = content[:100] + "..."
-	}
+	title := displayName(sender)
+	content := pushText(message)
 
 	// 为每个离线成员异步发送推送
 	for _, member := range members {
@@ -234,18 +599,78 @@ func (s *MessageService) sendOfflinePush(ctx context.Context, message *model.Mes
 	}
 }
 
+// pushText 离线推送的消息摘要
+func pushText(message *model.Message) string {
+	var content string
+	switch message.Type {
+	case 1: // text
+		content = message.Content
+	case 2: // image
+		content = "[图片]"
+	case 3: // file
+		content = "[文件]"
+	case 4: // voice
+		content = "[语音]"
+	case 5: // location
+		content = "[位置]"
+	case 6: // poll
+		content = "[投票] " + message.Content
+	}
+
+	// 限制内容长度
+	if len(content) > 100 {
+		content = content[:100] + "..."
+	}
+	return content
+}
+
 // SendMessageFromWS 从 WebSocket 发送消息（不重复广播）
-func (s *MessageService) SendMessageFromWS(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Message, error) {
-	// 验证逻辑与 SendMessage 相同，但不触发广播（因为 WebSocket 会直接发送）
-	_, err := s.chatRepo.FindByID(ctx, req.ChatID)
+// 验证逻辑和离线推送与 SendMessage 相同，但不触发广播（因为 WebSocket 会直接发送）
+// created 为 false 表示重复提交，返回的是已保存的消息，调用方不应再次广播
+func (s *MessageService) SendMessageFromWS(ctx context.Context, senderID int64, req *SendMessageRequest) (*model.Message, bool, error) {
+	message, chat, created, err := s.createMessage(ctx, senderID, req)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrChatNotFound
+		return nil, false, err
+	}
+	if created {
+		s.pushOffline(ctx, chat, message, senderID)
+	}
+	return message, created, nil
+}
+
+// GetMessagesRequest 获取聊天记录请求
+// BeforeSeqID、AfterSeqID、AroundMessageID 最多指定一个，都不指定时返回最新的消息
+type GetMessagesRequest struct {
+	ChatID          int64 `json:"chat_id"`
+	BeforeSeqID     int64 `json:"before_seq_id"`     // 返回比该 SeqID 更早的消息
+	AfterSeqID      int64 `json:"after_seq_id"`      // 返回比该 SeqID 更新的消息
+	AroundMessageID int64 `json:"around_message_id"` // 返回该消息及其前后的消息，用于跳转到被回复或置顶的消息
+	Limit           int   `json:"limit"`
+}
+
+// MessagePage 一页聊天记录，消息按 SeqID 从新到旧排列
+// 两个游标为 0 表示该方向没有更多消息
+type MessagePage struct {
+	Messages   []*model.Message `json:"messages"`
+	NextCursor int64            `json:"next_cursor"` // 加载更早的消息时作为 before_seq_id
+	PrevCursor int64            `json:"prev_cursor"` // 加载更新的消息时作为 after_seq_id
+}
+
+// GetMessages 按 SeqID 游标分页获取聊天记录
+// 游标不受分页期间新消息的影响，不会跳过或重复
+func (s *MessageService) GetMessages(ctx context.Context, userID int64, req *GetMessagesRequest) (*MessagePage, error) {
+	cursors := 0
+	for _, cursor := range []int64{req.BeforeSeqID, req.AfterSeqID, req.AroundMessageID} {
+		if cursor != 0 {
+			cursors++
 		}
-		return nil, err
+	}
+	if cursors > 1 {
+		return nil, ErrInvalidCursor
 	}
 
-	_, err = s.chatRepo.GetMember(ctx, req.ChatID, senderID)
+	// Check if user is a member of the chat
+	_, err := s.chatRepo.GetMember(ctx, req.ChatID, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotAuthorized
@@ -253,47 +678,119 @@ func (s *MessageService) SendMessageFromWS(ctx context.Context, senderID int64,
 		return nil, err
 	}
 
-	message := &model.Message{
-		SeqID:     snowflake.GenerateID(),
-		ChatID:    req.ChatID,
-		SenderID:  senderID,
-		Type:      req.Type,
-		Content:   req.Content,
-		MediaURL:  req.MediaURL,
-		Duration:  req.Duration,
-		Latitude:  req.Latitude,
-		Longitude: req.Longitude,
-		ReplyID:   req.ReplyID,
+	var page *MessagePage
+	switch {
+	case req.AfterSeqID != 0:
+		page, err = s.messagesAfter(ctx, req.ChatID, userID, req.AfterSeqID, req.Limit)
+	case req.AroundMessageID != 0:
+		page, err = s.messagesAround(ctx, req.ChatID, userID, req.AroundMessageID, req.Limit)
+	default:
+		page, err = s.messagesBefore(ctx, req.ChatID, userID, req.BeforeSeqID, req.Limit)
+	}
+	if err != nil {
+		s.logger.Error("failed to get messages", zap.Error(err))
+		return nil, err
 	}
+	messages := page.Messages
 
-	if err := s.messageRepo.Create(ctx, message); err != nil {
-		s.logger.Error("failed to create message from WS", zap.Error(err))
+	if err := s.fillReactions(ctx, messages, userID); err != nil {
+		s.logger.Error("failed to get reactions", zap.Error(err))
+		return nil, err
+	}
+	if err := s.fillReplyQuotes(ctx, messages); err != nil {
+		s.logger.Error("failed to get replied messages", zap.Error(err))
+		return nil, err
+	}
+	if err := s.fillPolls(ctx, messages, userID); err != nil {
+		s.logger.Error("failed to get polls", zap.Error(err))
 		return nil, err
 	}
 
-	return message, nil
+	return page, nil
 }
 
-func (s *MessageService) GetMessages(ctx context.Context, chatID, userID int64, offset, limit int) ([]*model.Message, error) {
-	// Check if user is a member of the chat
-	_, err := s.chatRepo.GetMember(ctx, chatID, userID)
+// messagesBefore 获取 beforeSeqID 之前的一页消息，beforeSeqID 为 0 时获取最新的一页
+func (s *MessageService) messagesBefore(ctx context.Context, chatID, userID, beforeSeqID int64, limit int) (*MessagePage, error) {
+	// 多查一条判断是否还有更早的消息
+	messages, err := s.messageRepo.FindByChatIDBefore(ctx, chatID, userID, beforeSeqID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = page.Messages[limit-1].SeqID
+	}
+	if beforeSeqID != 0 {
+		page.PrevCursor = beforeSeqID - 1
+		if len(page.Messages) > 0 {
+			page.PrevCursor = page.Messages[0].SeqID
+		}
+	}
+	return page, nil
+}
+
+// messagesAfter 获取 afterSeqID 之后的一页消息
+func (s *MessageService) messagesAfter(ctx context.Context, chatID, userID, afterSeqID int64, limit int) (*MessagePage, error) {
+	messages, err := s.messageRepo.FindByChatIDAfter(ctx, chatID, userID, afterSeqID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{NextCursor: afterSeqID + 1}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.PrevCursor = messages[limit-1].SeqID
+	}
+	page.Messages = reverseMessages(messages)
+	if len(page.Messages) > 0 {
+		page.NextCursor = page.Messages[len(page.Messages)-1].SeqID
+	}
+	return page, nil
+}
+
+// messagesAround 获取某条消息及其前后的消息，两侧各约占一半
+func (s *MessageService) messagesAround(ctx context.Context, chatID, userID, messageID int64, limit int) (*MessagePage, error) {
+	anchor, err := s.messageRepo.FindByID(ctx, messageID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotAuthorized
+			return nil, ErrMessageNotFound
 		}
 		return nil, err
 	}
+	if anchor.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
 
-	messages, err := s.messageRepo.FindByChatID(ctx, chatID, offset, limit)
+	// 锚点消息本身放在较新的一侧
+	newer, err := s.messagesAfter(ctx, chatID, userID, anchor.SeqID-1, limit-limit/2)
+	if err != nil {
+		return nil, err
+	}
+	older, err := s.messagesBefore(ctx, chatID, userID, anchor.SeqID, max(limit/2, 1))
 	if err != nil {
-		s.logger.Error("failed to get messages", zap.Error(err))
 		return nil, err
 	}
 
-	return messages, nil
+	return &MessagePage{
+		Messages:   append(newer.Messages, older.Messages...),
+		NextCursor: older.NextCursor,
+		PrevCursor: newer.PrevCursor,
+	}, nil
+}
+
+// reverseMessages 原地反转消息顺序
+func reverseMessages(messages []*model.Message) []*model.Message {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages
 }
 
-func (s *MessageService) DeleteMessage(ctx context.Context, messageID, userID int64) error {
+// DeleteMessage 删除消息
+// forMe 为 true 时仅对自己删除，任何成员都可以；否则对所有人删除，仅发送者或有删除消息权限的群组/频道管理员可以
+func (s *MessageService) DeleteMessage(ctx context.Context, messageID, userID int64, forMe bool) error {
 	message, err := s.messageRepo.FindByID(ctx, messageID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -301,13 +798,431 @@ func (s *MessageService) DeleteMessage(ctx context.Context, messageID, userID in
 		}
 		return err
 	}
+	if message.IsDeleted {
+		return ErrMessageNotFound
+	}
+
+	if forMe {
+		return s.deleteForMe(ctx, message, userID)
+	}
 
-	// Only sender can delete their own message
 	if message.SenderID != userID {
-		return ErrNotAuthorized
+		// 私聊中只能删除自己的消息
+		if _, _, err := requireRight(ctx, s.chatRepo, message.ChatID, userID, RightDeleteMessages); err != nil {
+			return err
+		}
 	}
 
-	return s.messageRepo.Delete(ctx, messageID)
+	return s.deleteForEveryone(ctx, message)
+}
+
+// deleteForEveryone 对所有人删除消息，并通知聊天室成员
+func (s *MessageService) deleteForEveryone(ctx context.Context, message *model.Message) error {
+	if err := s.messageRepo.Delete(ctx, message.ID, snowflake.GenerateID()); err != nil {
+		s.logger.Error("failed to delete message", zap.Error(err))
+		return err
+	}
+	if message.ReplyID != 0 {
+		if err := s.messageRepo.IncrementReplyCount(ctx, message.ReplyID, -1); err != nil {
+			s.logger.Error("failed to update reply count", zap.Error(err))
+		}
+	}
+	// 删除的消息不再置顶，客户端通过 message_deleted 事件自行移除，不再单独推送
+	if _, err := s.chatRepo.UnpinMessage(ctx, message.ChatID, message.ID); err != nil {
+		s.logger.Error("failed to unpin deleted message", zap.Error(err))
+	}
+	if s.searchIndex != nil {
+		if err := s.searchIndex.Remove(ctx, message.ID); err != nil {
+			s.logger.Error("failed to remove message from search index", zap.Error(err))
+		}
+	}
+
+	s.emitChatEvent(message.ChatID, EventMessageDeleted, &Tombstone{MessageID: message.ID, ChatID: message.ChatID})
+	return nil
+}
+
+// deleteForMe 仅对自己删除消息，通知自己的其他设备
+func (s *MessageService) deleteForMe(ctx context.Context, message *model.Message, userID int64) error {
+	if _, err := s.chatRepo.GetMember(ctx, message.ChatID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotAuthorized
+		}
+		return err
+	}
+
+	deletion := &model.MessageDeletion{
+		UserID:    userID,
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		SeqID:     snowflake.GenerateID(),
+	}
+	if err := s.messageRepo.CreateDeletion(ctx, deletion); err != nil {
+		s.logger.Error("failed to delete message for user", zap.Error(err))
+		return err
+	}
+	if s.searchIndex != nil {
+		if err := s.searchIndex.RemoveFor(ctx, userID, message.ID); err != nil {
+			s.logger.Error("failed to remove message from search index", zap.Error(err))
+		}
+	}
+
+	s.emitUserEvent(userID, EventMessageDeleted, &Tombstone{MessageID: message.ID, ChatID: message.ChatID, ForMe: true})
+	return nil
+}
+
+// EditMessage 编辑消息内容
+// 只有发送者可以编辑；频道中有编辑消息权限的管理员也可以编辑其他人发布的消息
+func (s *MessageService) EditMessage(ctx context.Context, messageID, userID int64, content string) (*model.Message, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if message.IsDeleted {
+		return nil, ErrMessageNotFound
+	}
+	if message.Type == 6 || message.Type == 7 { // 投票的问题和选项、服务消息不能修改
+		return nil, ErrNotAuthorized
+	}
+
+	if message.SenderID != userID {
+		chat, _, err := requireRight(ctx, s.chatRepo, message.ChatID, userID, RightEditMessages)
+		if err != nil {
+			return nil, err
+		}
+		if chat.Type != 3 { // 仅频道
+			return nil, ErrNotAuthorized
+		}
+	}
+
+	// 内容没有变化时不产生新的编辑记录
+	if message.Content == content {
+		return message, nil
+	}
+
+	edit := &model.MessageEdit{
+		MessageID:  message.ID,
+		EditorID:   userID,
+		OldContent: message.Content,
+	}
+	now := time.Now()
+	message.Content = content
+	message.EditedAt = &now
+	message.UpdateSeqID = snowflake.GenerateID()
+
+	if err := s.messageRepo.SaveEdit(ctx, message, edit); err != nil {
+		s.logger.Error("failed to edit message", zap.Error(err))
+		return nil, err
+	}
+	s.indexMessages(ctx, message)
+
+	s.emitChatEvent(message.ChatID, EventMessageEdited, message)
+	return message, nil
+}
+
+// GetMessageEdits 获取消息的编辑历史，仅聊天室成员可见
+func (s *MessageService) GetMessageEdits(ctx context.Context, messageID, userID int64) ([]*model.MessageEdit, error) {
+	if _, err := s.memberMessage(ctx, messageID, userID); err != nil {
+		return nil, err
+	}
+	return s.messageRepo.FindEdits(ctx, messageID)
+}
+
+// memberMessage 查询未删除的消息，并校验用户是该聊天室成员
+func (s *MessageService) memberMessage(ctx context.Context, messageID, userID int64) (*model.Message, error) {
+	message, err := s.messageRepo.FindByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if message.IsDeleted {
+		return nil, ErrMessageNotFound
+	}
+
+	if _, err := s.chatRepo.GetMember(ctx, message.ChatID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotAuthorized
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// PinUpdate message_pinned 事件的内容
+type PinUpdate struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int64 `json:"message_id"`
+	Pinned    bool  `json:"pinned"`  // false 表示取消置顶
+	UserID    int64 `json:"user_id"` // 操作人
+}
+
+// PinMessage 置顶消息
+// 群组和频道需要置顶权限，私聊双方都可以
+func (s *MessageService) PinMessage(ctx context.Context, messageID, userID int64) error {
+	message, err := s.pinnableMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	pin := &model.PinnedMessage{
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		PinnedBy:  userID,
+		PinnedAt:  time.Now(),
+	}
+	if err := s.chatRepo.PinMessage(ctx, pin); err != nil {
+		s.logger.Error("failed to pin message", zap.Error(err))
+		return err
+	}
+
+	s.emitChatEvent(message.ChatID, EventMessagePinned, &PinUpdate{
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		Pinned:    true,
+		UserID:    userID,
+	})
+	return nil
+}
+
+// UnpinMessage 取消置顶消息，权限与置顶相同
+func (s *MessageService) UnpinMessage(ctx context.Context, messageID, userID int64) error {
+	message, err := s.pinnableMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	removed, err := s.chatRepo.UnpinMessage(ctx, message.ChatID, message.ID)
+	if err != nil {
+		s.logger.Error("failed to unpin message", zap.Error(err))
+		return err
+	}
+
+	if removed {
+		s.emitChatEvent(message.ChatID, EventMessagePinned, &PinUpdate{
+			ChatID:    message.ChatID,
+			MessageID: message.ID,
+			Pinned:    false,
+			UserID:    userID,
+		})
+	}
+	return nil
+}
+
+// pinnableMessage 查询消息并校验用户有权置顶
+func (s *MessageService) pinnableMessage(ctx context.Context, messageID, userID int64) (*model.Message, error) {
+	message, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := requireRight(ctx, s.chatRepo, message.ChatID, userID, RightPin); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// ThreadPage 回复列表
+type ThreadPage struct {
+	Parent  *model.Message   `json:"parent"` // 被回复的消息，带有回复数
+	Replies []*model.Message `json:"replies"`
+}
+
+// GetReplies 分页获取回复某条消息的消息
+func (s *MessageService) GetReplies(ctx context.Context, messageID, userID int64, offset, limit int) (*ThreadPage, error) {
+	parent, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := s.messageRepo.FindReplies(ctx, messageID, userID, offset, limit)
+	if err != nil {
+		s.logger.Error("failed to get replies", zap.Error(err))
+		return nil, err
+	}
+	if err := s.fillReactions(ctx, replies, userID); err != nil {
+		s.logger.Error("failed to get reactions", zap.Error(err))
+		return nil, err
+	}
+	if err := s.fillPolls(ctx, append([]*model.Message{parent}, replies...), userID); err != nil {
+		s.logger.Error("failed to get polls", zap.Error(err))
+		return nil, err
+	}
+
+	quote := newQuote(parent)
+	for _, reply := range replies {
+		reply.ReplyTo = quote
+	}
+
+	return &ThreadPage{Parent: parent, Replies: replies}, nil
+}
+
+// fillReplyQuotes 为回复消息填充被回复消息的摘要，被回复消息已删除时不填充
+func (s *MessageService) fillReplyQuotes(ctx context.Context, messages []*model.Message) error {
+	replyIDs := make([]int64, 0)
+	for _, message := range messages {
+		if message.ReplyID != 0 {
+			replyIDs = append(replyIDs, message.ReplyID)
+		}
+	}
+	if len(replyIDs) == 0 {
+		return nil
+	}
+
+	parents, err := s.messageRepo.FindByIDs(ctx, replyIDs)
+	if err != nil {
+		return err
+	}
+
+	quotes := make(map[int64]*model.MessageQuote, len(parents))
+	for _, parent := range parents {
+		if !parent.IsDeleted {
+			quotes[parent.ID] = newQuote(parent)
+		}
+	}
+	for _, message := range messages {
+		if message.ReplyID != 0 {
+			message.ReplyTo = quotes[message.ReplyID]
+		}
+	}
+	return nil
+}
+
+// maxQuoteLength 引用摘要最多保留的字符数
+const maxQuoteLength = 100
+
+// newQuote 生成被回复消息的摘要
+func newQuote(message *model.Message) *model.MessageQuote {
+	content := []rune(message.Content)
+	if len(content) > maxQuoteLength {
+		content = append(content[:maxQuoteLength], []rune("...")...)
+	}
+	return &model.MessageQuote{
+		ID:       message.ID,
+		SenderID: message.SenderID,
+		Type:     message.Type,
+		Content:  string(content),
+		MediaURL: message.MediaURL,
+	}
+}
+
+// ReactionUpdate reaction_updated 事件的内容
+type ReactionUpdate struct {
+	MessageID int64                  `json:"message_id"`
+	ChatID    int64                  `json:"chat_id"`
+	UserID    int64                  `json:"user_id"`   // 变更回应的用户
+	Emoji     string                 `json:"emoji"`     // 变更后的回应，为空表示取消回应
+	Reactions []*model.ReactionCount `json:"reactions"` // 变更后的汇总，chosen 始终为 false，由客户端根据 user_id 判断
+}
+
+// SetReaction 对消息添加表情回应，已有回应时替换
+// 返回变更后的回应汇总
+func (s *MessageService) SetReaction(ctx context.Context, messageID, userID int64, emoji string) ([]*model.ReactionCount, error) {
+	message, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.chatRepo.FindByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	if !reactionAllowed(chat, emoji) {
+		return nil, ErrReactionNotAllowed
+	}
+
+	reaction := &model.MessageReaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+	}
+	if err := s.messageRepo.SetReaction(ctx, reaction); err != nil {
+		s.logger.Error("failed to set reaction", zap.Error(err))
+		return nil, err
+	}
+
+	return s.reactionsChanged(ctx, message, userID, emoji)
+}
+
+// RemoveReaction 取消对消息的表情回应
+// 返回变更后的回应汇总
+func (s *MessageService) RemoveReaction(ctx context.Context, messageID, userID int64) ([]*model.ReactionCount, error) {
+	message, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.messageRepo.DeleteReaction(ctx, messageID, userID); err != nil {
+		s.logger.Error("failed to remove reaction", zap.Error(err))
+		return nil, err
+	}
+
+	return s.reactionsChanged(ctx, message, userID, "")
+}
+
+// reactionsChanged 重新汇总消息的回应并推送 reaction_updated 事件
+func (s *MessageService) reactionsChanged(ctx context.Context, message *model.Message, userID int64, emoji string) ([]*model.ReactionCount, error) {
+	counts, err := s.messageRepo.FindReactionCounts(ctx, []int64{message.ID}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// chosen 只对当前用户有意义，推送给其他成员时清除
+	shared := make([]*model.ReactionCount, 0, len(counts))
+	for _, count := range counts {
+		shared = append(shared, &model.ReactionCount{Emoji: count.Emoji, Count: count.Count})
+	}
+	s.emitChatEvent(message.ChatID, EventReactionUpdated, &ReactionUpdate{
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Reactions: shared,
+	})
+
+	return counts, nil
+}
+
+// fillReactions 为消息列表填充表情回应汇总
+func (s *MessageService) fillReactions(ctx context.Context, messages []*model.Message, userID int64) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	counts, err := s.messageRepo.FindReactionCounts(ctx, messageIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	byMessage := make(map[int64][]*model.ReactionCount)
+	for _, count := range counts {
+		byMessage[count.MessageID] = append(byMessage[count.MessageID], count)
+	}
+	for _, message := range messages {
+		message.Reactions = byMessage[message.ID]
+	}
+	return nil
+}
+
+// reactionAllowed 检查聊天室是否允许使用该表情回应
+func reactionAllowed(chat *model.Chat, emoji string) bool {
+	if len(chat.AvailableReactions) == 0 {
+		return true
+	}
+	for _, allowed := range chat.AvailableReactions {
+		if allowed == emoji {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *MessageService) GetMessageByID(ctx context.Context, messageID int64) (*model.Message, error) {
@@ -318,11 +1233,8 @@ func (s *MessageService) GetMessageByID(ctx context.Context, messageID int64) (*
 // 返回成功标记为已读的消息列表
 func (s *MessageService) AckMessages(ctx context.Context, userID, chatID int64, messageIDs []int64) ([]*model.Message, error) {
 	// 验证用户是否属于该聊天室
-	_, err := s.chatRepo.GetMember(ctx, chatID, userID)
+	chat, _, err := chatMember(ctx, s.chatRepo, chatID, userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotAuthorized
-		}
 		return nil, err
 	}
 
@@ -333,20 +1245,149 @@ func (s *MessageService) AckMessages(ctx context.Context, userID, chatID int64,
 		return nil, err
 	}
 
+	// 群聊中每个成员的已读进度独立记录，已读位置只前进不后退
+	var lastReadSeqID int64
+	for _, message := range readMessages {
+		lastReadSeqID = max(lastReadSeqID, message.SeqID)
+	}
+	if lastReadSeqID > 0 {
+		if chat.Type == 3 {
+			s.countViews(ctx, chatID, userID, readMessages)
+		}
+		state := &model.ChatReadState{
+			ChatID:        chatID,
+			UserID:        userID,
+			LastReadSeqID: lastReadSeqID,
+			SeqID:         snowflake.GenerateID(),
+		}
+		if _, err := s.chatRepo.AdvanceReadState(ctx, state); err != nil {
+			s.logger.Error("failed to update read state", zap.Error(err))
+			return nil, err
+		}
+	}
+
 	return readMessages, nil
 }
 
-// SyncRequest 增量同步请求
-type SyncRequest struct {
-	LastSeqID int64 `json:"last_seq_id"`
+// countViews 频道消息被订阅者首次读到时增加浏览数
+// 已读位置只前进不后退，已读位置之后的消息才是第一次读到
+func (s *MessageService) countViews(ctx context.Context, chatID, userID int64, readMessages []*model.Message) {
+	var lastReadSeqID int64
+	state, err := s.chatRepo.GetReadState(ctx, chatID, userID)
+	if err == nil {
+		lastReadSeqID = state.LastReadSeqID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("failed to get read state", zap.Error(err))
+		return
+	}
+
+	ids := make([]int64, 0, len(readMessages))
+	for _, message := range readMessages {
+		if message.SeqID > lastReadSeqID {
+			ids = append(ids, message.ID)
+			message.Views++
+		}
+	}
+	if err := s.messageRepo.IncrementViews(ctx, ids); err != nil {
+		s.logger.Error("failed to increment message views", zap.Error(err))
+	}
+}
+
+// GetReadBy 获取已读某条消息的成员，只有消息的发送者可以查看
+// 返回的 UpdatedAt 是成员已读位置最后一次前进的时间
+func (s *MessageService) GetReadBy(ctx context.Context, messageID, userID int64) ([]*model.ChatReadState, error) {
+	message, err := s.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, ErrNotAuthorized
+	}
+
+	states, err := s.chatRepo.GetReadStates(ctx, message.ChatID, message.SeqID)
+	if err != nil {
+		s.logger.Error("failed to get read states", zap.Error(err))
+		return nil, err
+	}
+
+	readers := make([]*model.ChatReadState, 0, len(states))
+	for _, state := range states {
+		if state.UserID != userID {
+			readers = append(readers, state)
+		}
+	}
+	return readers, nil
+}
+
+// maxDeliveryAcks 一次最多记录送达的消息数
+const maxDeliveryAcks = 500
+
+// AckDelivered 记录设备已收到指定的消息
+// 只返回首次送达的消息，调用者据此通知发送者
+func (s *MessageService) AckDelivered(ctx context.Context, userID int64, messageIDs []int64) ([]*model.Message, error) {
+	if len(messageIDs) == 0 {
+		return []*model.Message{}, nil
+	}
+	return s.markDelivered(ctx, userID, messageIDs, 0)
+}
+
+// AckDeliveredUpTo 记录设备已收到 SeqID 不大于 lastSeqID 的所有消息，用于增量同步
+func (s *MessageService) AckDeliveredUpTo(ctx context.Context, userID, lastSeqID int64) ([]*model.Message, error) {
+	if lastSeqID <= 0 {
+		return []*model.Message{}, nil
+	}
+	return s.markDelivered(ctx, userID, nil, lastSeqID)
+}
+
+// markDelivered 将用户所在聊天室中其他用户发送、尚未送达的消息标记为已送达
+func (s *MessageService) markDelivered(ctx context.Context, userID int64, messageIDs []int64, maxSeqID int64) ([]*model.Message, error) {
+	chatIDs, err := s.chatRepo.GetChatIDsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get user chat IDs", zap.Error(err))
+		return nil, err
+	}
+	if len(chatIDs) == 0 {
+		return []*model.Message{}, nil
+	}
+
+	messages, err := s.messageRepo.FindUndelivered(ctx, chatIDs, userID, messageIDs, maxSeqID, maxDeliveryAcks)
+	if err != nil {
+		s.logger.Error("failed to find undelivered messages", zap.Error(err))
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	now := time.Now()
+	ids := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		message.DeliveredAt = &now
+	}
+	if err := s.messageRepo.MarkDelivered(ctx, ids, now); err != nil {
+		s.logger.Error("failed to mark messages delivered", zap.Error(err))
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Tombstone 被删除消息的墓碑，客户端据此移除本地缓存
+// 同时作为 message_deleted 事件的内容
+type Tombstone struct {
+	MessageID int64 `json:"message_id"`
+	ChatID    int64 `json:"chat_id"`
+	ForMe     bool  `json:"for_me"` // 仅对当前用户删除，其他成员仍可见
 }
 
 // SyncResponse 增量同步响应
 type SyncResponse struct {
-	Messages   []*model.Message `json:"messages"`
-	ReadAcks   []*model.Message `json:"read_acks"` // 已读确认的消息
-	SeqID      int64            `json:"seq_id"`     // 当前最新SeqID
-	HasMore    bool             `json:"has_more"`  // 告诉前端是否还有更多消息
+	Messages []*model.Message       `json:"messages"`
+	Edits    []*model.Message       `json:"edits"`     // 已同步过但在此之后被编辑的消息
+	Deleted  []*Tombstone           `json:"deleted"`   // 已同步过但在此之后被删除的消息
+	ReadAcks []*model.ChatReadState `json:"read_acks"` // 成员已读位置的变化，包括自己在其他设备上的已读
+	SeqID    int64                  `json:"seq_id"`    // 当前最新SeqID
+	HasMore  bool                   `json:"has_more"`  // 告诉前端是否还有更多消息
 }
 
 // Sync 增量同步 - 获取lastSeqID之后的所有消息
@@ -360,7 +1401,9 @@ func (s *MessageService) Sync(ctx context.Context, userID int64, lastSeqID int64
 	if len(chatIDs) == 0 {
 		return &SyncResponse{
 			Messages: []*model.Message{},
-			ReadAcks: []*model.Message{},
+			Edits:    []*model.Message{},
+			Deleted:  []*Tombstone{},
+			ReadAcks: []*model.ChatReadState{},
 			SeqID:    lastSeqID,
 			HasMore:  false,
 		}, nil
@@ -368,25 +1411,90 @@ func (s *MessageService) Sync(ctx context.Context, userID int64, lastSeqID int64
 
 	// 强制限制一次最多拉取 500 条
 	limit := 500
-	messages, err := s.messageRepo.FindBySeqIDsGreaterThan(ctx, chatIDs, lastSeqID, limit)
+	messages, err := s.messageRepo.FindBySeqIDsGreaterThan(ctx, chatIDs, userID, lastSeqID, limit)
 	if err != nil {
 		s.logger.Error("failed to sync messages", zap.Error(err))
 		return nil, err
 	}
 
+	// 新消息、编辑/删除、仅对自己删除、已读位置四类变更共用同一个序列
+	// 任意一类拉满 limit 时，游标停在它的最后一条（bound），之后的几类只取到 bound 为止
+	// bound 之后已返回的变更下次会重复返回，由前端按 SeqID 去重
+	var bound int64
+	if len(messages) == limit {
+		bound = messages[len(messages)-1].SeqID
+	}
+
+	// 已同步过的消息之后又被编辑或删除
+	updates, err := s.messageRepo.FindUpdatedBetween(ctx, chatIDs, lastSeqID, bound, limit)
+	if err != nil {
+		s.logger.Error("failed to sync edits", zap.Error(err))
+		return nil, err
+	}
+	if len(updates) == limit {
+		bound = updates[len(updates)-1].UpdateSeqID
+	}
+
+	deletions, err := s.messageRepo.FindDeletionsBetween(ctx, userID, lastSeqID, bound, limit)
+	if err != nil {
+		s.logger.Error("failed to sync deletions", zap.Error(err))
+		return nil, err
+	}
+	if len(deletions) == limit {
+		bound = deletions[len(deletions)-1].SeqID
+	}
+
+	readAcks, err := s.chatRepo.FindReadStatesBetween(ctx, chatIDs, lastSeqID, bound, limit)
+	if err != nil {
+		s.logger.Error("failed to sync read states", zap.Error(err))
+		return nil, err
+	}
+	if len(readAcks) == limit {
+		bound = readAcks[len(readAcks)-1].SeqID
+	}
+
+	if err := s.fillReplyQuotes(ctx, messages); err != nil {
+		s.logger.Error("failed to get replied messages", zap.Error(err))
+		return nil, err
+	}
+	if err := s.fillPolls(ctx, messages, userID); err != nil {
+		s.logger.Error("failed to get polls", zap.Error(err))
+		return nil, err
+	}
+
+	edits := make([]*model.Message, 0, len(updates))
+	deleted := make([]*Tombstone, 0, len(updates)+len(deletions))
 	currentSeqID := lastSeqID
 	for _, msg := range messages {
-		if msg.SeqID > currentSeqID {
-			currentSeqID = msg.SeqID
+		currentSeqID = max(currentSeqID, msg.SeqID)
+	}
+	for _, msg := range updates {
+		if msg.IsDeleted {
+			deleted = append(deleted, &Tombstone{MessageID: msg.ID, ChatID: msg.ChatID})
+		} else {
+			edits = append(edits, msg)
 		}
+		currentSeqID = max(currentSeqID, msg.UpdateSeqID)
+	}
+	for _, d := range deletions {
+		deleted = append(deleted, &Tombstone{MessageID: d.MessageID, ChatID: d.ChatID, ForMe: true})
+		currentSeqID = max(currentSeqID, d.SeqID)
+	}
+	for _, state := range readAcks {
+		currentSeqID = max(currentSeqID, state.SeqID)
 	}
 
-	// 如果拉回来的消息数量等于 limit，说明数据库里很可能还有没拉完的数据
-	hasMore := len(messages) == limit
+	// 如果拉回来的数量等于 limit，说明数据库里很可能还有没拉完的数据
+	hasMore := bound > 0
+	if hasMore {
+		currentSeqID = bound
+	}
 
 	return &SyncResponse{
 		Messages: messages,
-		ReadAcks: []*model.Message{}, // 暂不实现已读同步
+		Edits:    edits,
+		Deleted:  deleted,
+		ReadAcks: readAcks,
 		SeqID:    currentSeqID,
 		HasMore:  hasMore,
 	}, nil
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+	"github.com/forever-free1/telegram-go/backend/pkg/snowflake"
+)
+
+// 以下请求在查询数据库之前就会被拒绝
+func TestCheckSend_RejectsInvalidRequest(t *testing.T) {
+	s := &MessageService{}
+
+	tests := []struct {
+		name string
+		req  *SendMessageRequest
+		err  error
+	}{
+		{"service message", &SendMessageRequest{ChatID: 1, Type: 7, Content: "hi"}, ErrNotAuthorized},
+		{"negative ttl", &SendMessageRequest{ChatID: 1, Type: 1, Content: "hi", TTL: -1}, ErrInvalidTTL},
+		{"ttl over 7 days", &SendMessageRequest{ChatID: 1, Type: 1, Content: "hi", TTL: maxMessageTTL + 1}, ErrInvalidTTL},
+		{"client_msg_id too long", &SendMessageRequest{ChatID: 1, Type: 1, Content: "hi", ClientMsgID: strings.Repeat("a", maxClientMsgIDLength+1)}, ErrInvalidClientMsgID},
+		{"reserved client_msg_id prefix", &SendMessageRequest{ChatID: 1, Type: 1, Content: "hi", ClientMsgID: "sched-42"}, ErrReservedClientMsgID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.checkSend(context.Background(), 1, tt.req)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+// testMessageID 假数据库中消息的ID，消息由 senderID 在 testChatID 中发送
+const testMessageID = 50
+
+// newMessageFixture 在 newChatFixture 的基础上加入一条消息，返回使用假数据库的 MessageService
+func newMessageFixture(t *testing.T, chatType int, senderID int64, members ...*model.ChatMember) (*testutil.FakeDB, *MessageService) {
+	_, err := snowflake.NewSnowflake(1)
+	require.NoError(t, err)
+	fake, db := newChatFixture(t, chatType, members...)
+	fake.On("FROM `messages`", &testutil.Rows{
+		Columns: []string{"id", "chat_id", "sender_id", "type", "content"},
+		Values:  [][]driver.Value{{int64(testMessageID), int64(testChatID), senderID, int64(1), "hello"}},
+	})
+	s := NewMessageService(
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		repository.NewPollRepository(db),
+		repository.NewUserRepository(db),
+		zap.NewNop(),
+	)
+	return fake, s
+}
+
+// messagePermissionCase 消息操作的权限测试用例，actorID 对 senderID 发送的消息进行操作
+type messagePermissionCase struct {
+	name     string
+	chatType int
+	members  []*model.ChatMember
+	senderID int64
+	actorID  int64
+	err      error
+}
+
+var (
+	msgOwner   = testMember(11, RoleOwner, 0)
+	msgAdmin   = testMember(12, RoleAdmin, DefaultAdminRights)
+	msgPinner  = testMember(13, RoleAdmin, RightPin)
+	msgMember  = testMember(14, RoleMember, 0)
+	msgPeer    = testMember(15, RoleMember, 0)
+	msgMembers = []*model.ChatMember{msgOwner, msgAdmin, msgPinner, msgMember, msgPeer}
+)
+
+// runMessagePermissionCases 执行权限测试用例，操作成功时应执行包含 exec 的语句，失败时不应执行
+func runMessagePermissionCases(t *testing.T, tests []messagePermissionCase, exec string, op func(s *MessageService, actorID int64) error) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, s := newMessageFixture(t, tt.chatType, tt.senderID, tt.members...)
+
+			err := op(s, tt.actorID)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.err == nil, len(fake.Executed(exec)) > 0)
+		})
+	}
+}
+
+func TestMessageService_DeleteForEveryone(t *testing.T) {
+	runMessagePermissionCases(t, []messagePermissionCase{
+		{"private sender deletes own", 1, []*model.ChatMember{msgOwner, msgPeer}, 15, 15, nil},
+		{"private peer cannot delete others'", 1, []*model.ChatMember{msgOwner, msgPeer}, 11, 15, ErrNotAuthorized},
+		{"private owner cannot delete others'", 1, []*model.ChatMember{msgOwner, msgPeer}, 15, 11, ErrNotAuthorized},
+		{"group owner deletes others'", 2, msgMembers, 14, 11, nil},
+		{"group admin with delete right", 2, msgMembers, 14, 12, nil},
+		{"group admin without delete right", 2, msgMembers, 14, 13, ErrNotAuthorized},
+		{"group member cannot delete others'", 2, msgMembers, 14, 15, ErrNotAuthorized},
+		{"non-member cannot delete", 2, msgMembers, 14, 30, ErrNotAuthorized},
+		{"channel admin with delete right", 3, msgMembers, 11, 12, nil},
+		{"channel subscriber cannot delete", 3, msgMembers, 11, 14, ErrNotAuthorized},
+	}, "UPDATE `messages`", func(s *MessageService, actorID int64) error {
+		return s.DeleteMessage(context.Background(), testMessageID, actorID, false)
+	})
+}
+
+func TestMessageService_DeleteForMe(t *testing.T) {
+	runMessagePermissionCases(t, []messagePermissionCase{
+		{"private peer", 1, []*model.ChatMember{msgOwner, msgPeer}, 11, 15, nil},
+		{"group member", 2, msgMembers, 11, 14, nil},
+		{"channel subscriber", 3, msgMembers, 11, 14, nil},
+		{"non-member", 2, msgMembers, 11, 30, ErrNotAuthorized},
+	}, "INSERT INTO `message_deletions`", func(s *MessageService, actorID int64) error {
+		return s.DeleteMessage(context.Background(), testMessageID, actorID, true)
+	})
+}
+
+func TestMessageService_EditMessage(t *testing.T) {
+	runMessagePermissionCases(t, []messagePermissionCase{
+		{"private sender edits own", 1, []*model.ChatMember{msgOwner, msgPeer}, 15, 15, nil},
+		{"private peer cannot edit others'", 1, []*model.ChatMember{msgOwner, msgPeer}, 11, 15, ErrNotAuthorized},
+		{"group member edits own", 2, msgMembers, 14, 14, nil},
+		{"group owner cannot edit others'", 2, msgMembers, 14, 11, ErrNotAuthorized},
+		{"group admin cannot edit others'", 2, msgMembers, 14, 12, ErrNotAuthorized},
+		{"channel owner edits others' posts", 3, msgMembers, 12, 11, nil},
+		{"channel admin with edit right", 3, msgMembers, 11, 12, nil},
+		{"channel admin without edit right", 3, msgMembers, 11, 13, ErrNotAuthorized},
+		{"channel subscriber cannot edit", 3, msgMembers, 11, 14, ErrNotAuthorized},
+	}, "INSERT INTO `message_edits`", func(s *MessageService, actorID int64) error {
+		_, err := s.EditMessage(context.Background(), testMessageID, actorID, "edited")
+		return err
+	})
+}
+
+func TestMessageService_PinMessage(t *testing.T) {
+	runMessagePermissionCases(t, []messagePermissionCase{
+		{"private owner", 1, []*model.ChatMember{msgOwner, msgPeer}, 15, 11, nil},
+		{"private peer", 1, []*model.ChatMember{msgOwner, msgPeer}, 11, 15, nil},
+		{"group owner", 2, msgMembers, 14, 11, nil},
+		{"group admin with pin right", 2, msgMembers, 14, 13, nil},
+		{"group member cannot pin", 2, msgMembers, 14, 14, ErrNotAuthorized},
+		{"non-member cannot pin", 2, msgMembers, 14, 30, ErrNotAuthorized},
+		{"channel admin with pin right", 3, msgMembers, 11, 12, nil},
+		{"channel subscriber cannot pin", 3, msgMembers, 11, 14, ErrNotAuthorized},
+	}, "INSERT INTO `pinned_messages`", func(s *MessageService, actorID int64) error {
+		return s.PinMessage(context.Background(), testMessageID, actorID)
+	})
+}
+
+func TestMessageService_UnpinMessage(t *testing.T) {
+	runMessagePermissionCases(t, []messagePermissionCase{
+		{"private peer", 1, []*model.ChatMember{msgOwner, msgPeer}, 11, 15, nil},
+		{"group admin with pin right", 2, msgMembers, 14, 13, nil},
+		{"group member cannot unpin", 2, msgMembers, 14, 14, ErrNotAuthorized},
+		{"channel subscriber cannot unpin", 3, msgMembers, 11, 14, ErrNotAuthorized},
+	}, "DELETE FROM `pinned_messages`", func(s *MessageService, actorID int64) error {
+		return s.UnpinMessage(context.Background(), testMessageID, actorID)
+	})
+}
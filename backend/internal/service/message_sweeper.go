@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+// sweeperBatchSize 每轮最多删除的到期消息数
+const sweeperBatchSize = 100
+
+// MessageSweeper 定期删除到期的自毁消息
+// 到期时间由聊天室的自动删除设置（从发送时计时）或消息的阅后即焚设置（从首次被读计时）决定
+type MessageSweeper struct {
+	messageRepo    *repository.MessageRepository
+	messageService *MessageService
+	fileService    *FileService
+	logger         *zap.Logger
+}
+
+func NewMessageSweeper(
+	messageRepo *repository.MessageRepository,
+	messageService *MessageService,
+	fileService *FileService,
+	logger *zap.Logger,
+) *MessageSweeper {
+	return &MessageSweeper{
+		messageRepo:    messageRepo,
+		messageService: messageService,
+		fileService:    fileService,
+		logger:         logger,
+	}
+}
+
+// Run 定期删除到期的消息，直到 ctx 结束
+func (s *MessageSweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+// sweep 删除一批到期的消息，删除后与手动删除一样推送 message_deleted 事件
+func (s *MessageSweeper) sweep(ctx context.Context) {
+	expired, err := s.messageRepo.FindExpired(ctx, time.Now(), sweeperBatchSize)
+	if err != nil {
+		s.logger.Error("failed to find expired messages", zap.Error(err))
+		return
+	}
+
+	for _, message := range expired {
+		if err := s.messageService.deleteForEveryone(ctx, message); err != nil {
+			continue
+		}
+		s.deleteMedia(ctx, message)
+	}
+}
+
+// deleteMedia 删除消息上传的媒体文件，仍有其他消息（如转发）引用时保留
+func (s *MessageSweeper) deleteMedia(ctx context.Context, message *model.Message) {
+	if message.MediaURL == "" || s.fileService == nil {
+		return
+	}
+
+	relativePath, ok := s.fileService.RelativePath(message.MediaURL)
+	if !ok {
+		return
+	}
+
+	count, err := s.messageRepo.CountByMediaURL(ctx, message.MediaURL)
+	if err != nil {
+		s.logger.Error("failed to count media references", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	if err := s.fileService.DeleteFile(ctx, relativePath); err != nil {
+		s.logger.Warn("failed to delete expired media", zap.String("path", relativePath), zap.Error(err))
+	}
+}
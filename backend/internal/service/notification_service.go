@@ -13,6 +13,9 @@ type PushService interface {
 	// Push 发送离线推送
 	Push(ctx context.Context, userID int64, title, content string, data map[string]string) error
 
+	// PushBatch 向多个用户发送同一条离线推送，用于频道等大聊天室
+	PushBatch(ctx context.Context, userIDs []int64, title, content string, data map[string]string) error
+
 	// PushToDevice 发送离线推送到指定设备
 	PushToDevice(ctx context.Context, fcmToken, title, content string, data map[string]string) error
 
@@ -35,6 +38,8 @@ type NotificationService struct {
 	// 模拟在线用户检查
 	onlineUsers map[int64]bool
 	mu         sync.RWMutex
+	// 在线状态检查回调，由 WebSocket Hub 提供，支持跨节点
+	presenceChecker func(userID int64) bool
 }
 
 // NewNotificationService 创建推送服务
@@ -74,6 +79,22 @@ func (s *NotificationService) Push(ctx context.Context, userID int64, title, con
 	return nil
 }
 
+// PushBatch 向多个用户发送同一条离线推送
+// 调用者已经过滤掉在线用户，实际实现应使用 FCM 的批量发送接口
+func (s *NotificationService) PushBatch(ctx context.Context, userIDs []int64, title, content string, data map[string]string) error {
+	// TODO: 实现实际的 FCM 批量推送
+
+	s.logger.Info("sending batched offline push",
+		zap.Int("recipients", len(userIDs)),
+		zap.String("title", title),
+		zap.String("content", content))
+
+	// Mock: 打印推送信息
+	log.Printf("[MOCK PUSH] to %d users: %s - %s", len(userIDs), title, content)
+
+	return nil
+}
+
 // PushToDevice 发送离线推送到指定设备
 func (s *NotificationService) PushToDevice(ctx context.Context, fcmToken, title, content string, data map[string]string) error {
 	// TODO: 实现实际的 FCM 推送到指定设备
@@ -119,11 +140,21 @@ func (s *NotificationService) SetUserOnline(userID int64, online bool) {
 	s.onlineUsers[userID] = online
 }
 
+// SetPresenceChecker 设置在线状态检查回调
+func (s *NotificationService) SetPresenceChecker(checker func(userID int64) bool) {
+	s.presenceChecker = checker
+}
+
 // IsUserOnline 检查用户是否在线
 func (s *NotificationService) IsUserOnline(userID int64) bool {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.onlineUsers[userID]
+	online := s.onlineUsers[userID]
+	s.mu.RUnlock()
+
+	if !online && s.presenceChecker != nil {
+		return s.presenceChecker(userID)
+	}
+	return online
 }
 
 // NotificationPayload 推送消息体
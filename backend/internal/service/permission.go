@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+)
+
+// 聊天室成员角色，对应 ChatMember.Role
+const (
+	RoleMember = 1
+	RoleAdmin  = 2
+	RoleOwner  = 3
+)
+
+// Right 管理员权限，多个权限按位组合保存在 ChatMember.Permissions 中
+type Right int64
+
+const (
+	RightInvite         Right = 1 << iota // 邀请成员
+	RightKick                             // 移除成员
+	RightPin                              // 置顶消息
+	RightEditInfo                         // 修改聊天室设置
+	RightDeleteMessages                   // 删除其他人的消息
+	RightEditMessages                     // 编辑其他人在频道中发布的消息
+	RightManageAdmins                     // 任命管理员和修改管理员权限
+	RightPostMessages                     // 在频道中发布消息
+
+	// RightsAll 所有权限，所有者始终拥有
+	RightsAll = RightInvite | RightKick | RightPin | RightEditInfo | RightDeleteMessages | RightEditMessages | RightManageAdmins | RightPostMessages
+	// DefaultAdminRights 任命管理员时未指定权限使用的默认权限
+	DefaultAdminRights = RightsAll &^ RightManageAdmins
+)
+
+// ErrInvalidRights 权限中包含未知的位
+var ErrInvalidRights = errors.New("invalid admin rights")
+
+// privateRights 私聊双方都拥有的权限
+const privateRights = RightPin | RightEditInfo
+
+// HasRight 判断成员在聊天室中是否拥有指定的权限
+// 私聊双方只能置顶消息和修改设置；群组和频道中所有者拥有全部权限，管理员按 Permissions 授予，普通成员没有管理权限
+func HasRight(chat *model.Chat, member *model.ChatMember, right Right) bool {
+	if chat == nil || member == nil || member.ChatID != chat.ID {
+		return false
+	}
+	if chat.Type == 1 {
+		return privateRights&right == right
+	}
+	switch member.Role {
+	case RoleOwner:
+		return true
+	case RoleAdmin:
+		return Right(member.Permissions)&right == right
+	}
+	return false
+}
+
+// memberRights 返回成员拥有的全部权限
+func memberRights(chat *model.Chat, member *model.ChatMember) Right {
+	var rights Right
+	for right := Right(1); right <= RightsAll; right <<= 1 {
+		if HasRight(chat, member, right) {
+			rights |= right
+		}
+	}
+	return rights
+}
+
+// canManageMember 判断 actor 能否移除 target 或修改其权限
+// 只能管理角色比自己低的成员，所有者不能被管理；管理其他管理员只有所有者可以
+func canManageMember(chat *model.Chat, actor, target *model.ChatMember, right Right) bool {
+	if chat.Type == 1 || actor.UserID == target.UserID || actor.Role <= target.Role {
+		return false
+	}
+	return HasRight(chat, actor, right)
+}
+
+// chatMember 查询聊天室以及用户的成员信息
+// 聊天室不存在返回 ErrChatNotFound，用户不是成员返回 ErrNotAuthorized
+func chatMember(ctx context.Context, chatRepo *repository.ChatRepository, chatID, userID int64) (*model.Chat, *model.ChatMember, error) {
+	chat, err := chatRepo.FindByID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrChatNotFound
+		}
+		return nil, nil, err
+	}
+	member, err := chatRepo.GetMember(ctx, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNotAuthorized
+		}
+		return nil, nil, err
+	}
+	return chat, member, nil
+}
+
+// requireRight 查询聊天室并校验用户拥有指定的权限
+func requireRight(ctx context.Context, chatRepo *repository.ChatRepository, chatID, userID int64, right Right) (*model.Chat, *model.ChatMember, error) {
+	chat, member, err := chatMember(ctx, chatRepo, chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !HasRight(chat, member, right) {
+		return nil, nil, ErrNotAuthorized
+	}
+	return chat, member, nil
+}
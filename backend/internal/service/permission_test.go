@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+)
+
+func TestHasRight(t *testing.T) {
+	private := &model.Chat{ID: 1, Type: 1}
+	group := &model.Chat{ID: 2, Type: 2}
+	channel := &model.Chat{ID: 3, Type: 3}
+
+	member := func(chat *model.Chat, role int, rights Right) *model.ChatMember {
+		return &model.ChatMember{ChatID: chat.ID, UserID: 100, Role: role, Permissions: int64(rights)}
+	}
+
+	tests := []struct {
+		name   string
+		chat   *model.Chat
+		member *model.ChatMember
+		right  Right
+		want   bool
+	}{
+		{"not a member", group, nil, RightPin, false},
+		{"member of another chat", group, member(channel, RoleOwner, 0), RightPin, false},
+		{"private chat can pin", private, member(private, RoleMember, 0), RightPin, true},
+		{"private chat can edit info", private, member(private, RoleMember, 0), RightEditInfo, true},
+		{"private chat cannot delete others' messages", private, member(private, RoleOwner, 0), RightDeleteMessages, false},
+		{"group member cannot pin", group, member(group, RoleMember, 0), RightPin, false},
+		{"group member ignores stray permissions", group, member(group, RoleMember, RightsAll), RightKick, false},
+		{"group owner has every right", group, member(group, RoleOwner, 0), RightManageAdmins, true},
+		{"admin with right", group, member(group, RoleAdmin, RightKick|RightPin), RightKick, true},
+		{"admin without right", group, member(group, RoleAdmin, RightKick|RightPin), RightInvite, false},
+		{"admin needs every requested right", group, member(group, RoleAdmin, RightKick), RightKick | RightPin, false},
+		{"default admin cannot manage admins", channel, member(channel, RoleAdmin, DefaultAdminRights), RightManageAdmins, false},
+		{"default admin can edit channel posts", channel, member(channel, RoleAdmin, DefaultAdminRights), RightEditMessages, true},
+		{"default admin can post in channel", channel, member(channel, RoleAdmin, DefaultAdminRights), RightPostMessages, true},
+		{"admin without post right", channel, member(channel, RoleAdmin, RightPin), RightPostMessages, false},
+		{"subscriber cannot post in channel", channel, member(channel, RoleMember, 0), RightPostMessages, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasRight(tt.chat, tt.member, tt.right))
+		})
+	}
+}
+
+func TestCanManageMember(t *testing.T) {
+	private := &model.Chat{ID: 1, Type: 1}
+	group := &model.Chat{ID: 2, Type: 2}
+
+	owner := &model.ChatMember{ChatID: group.ID, UserID: 1, Role: RoleOwner}
+	admin := &model.ChatMember{ChatID: group.ID, UserID: 2, Role: RoleAdmin, Permissions: int64(RightKick | RightManageAdmins)}
+	otherAdmin := &model.ChatMember{ChatID: group.ID, UserID: 3, Role: RoleAdmin, Permissions: int64(DefaultAdminRights)}
+	pinOnly := &model.ChatMember{ChatID: group.ID, UserID: 4, Role: RoleAdmin, Permissions: int64(RightPin)}
+	member := &model.ChatMember{ChatID: group.ID, UserID: 5, Role: RoleMember}
+	privateOwner := &model.ChatMember{ChatID: private.ID, UserID: 1, Role: RoleOwner}
+	privateMember := &model.ChatMember{ChatID: private.ID, UserID: 2, Role: RoleMember}
+
+	tests := []struct {
+		name   string
+		chat   *model.Chat
+		actor  *model.ChatMember
+		target *model.ChatMember
+		right  Right
+		want   bool
+	}{
+		{"owner kicks member", group, owner, member, RightKick, true},
+		{"owner kicks admin", group, owner, admin, RightKick, true},
+		{"owner demotes admin", group, owner, otherAdmin, RightManageAdmins, true},
+		{"admin kicks member", group, admin, member, RightKick, true},
+		{"admin promotes member", group, admin, member, RightManageAdmins, true},
+		{"admin without kick right", group, pinOnly, member, RightKick, false},
+		{"admin cannot kick another admin", group, admin, otherAdmin, RightKick, false},
+		{"admin cannot kick owner", group, admin, owner, RightKick, false},
+		{"member cannot kick member", group, member, &model.ChatMember{ChatID: group.ID, UserID: 6, Role: RoleMember}, RightKick, false},
+		{"cannot manage self", group, owner, owner, RightManageAdmins, false},
+		{"private chat members cannot be managed", private, privateOwner, privateMember, RightKick, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, canManageMember(tt.chat, tt.actor, tt.target, tt.right))
+		})
+	}
+}
+
+func TestMemberRights(t *testing.T) {
+	group := &model.Chat{ID: 2, Type: 2}
+	private := &model.Chat{ID: 1, Type: 1}
+
+	assert.Equal(t, RightsAll, memberRights(group, &model.ChatMember{ChatID: group.ID, Role: RoleOwner}))
+	assert.Equal(t, RightKick|RightPin, memberRights(group, &model.ChatMember{ChatID: group.ID, Role: RoleAdmin, Permissions: int64(RightKick | RightPin)}))
+	assert.Equal(t, Right(0), memberRights(group, &model.ChatMember{ChatID: group.ID, Role: RoleMember}))
+	assert.Equal(t, privateRights, memberRights(private, &model.ChatMember{ChatID: private.ID, Role: RoleOwner}))
+}
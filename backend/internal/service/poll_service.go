@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrInvalidPoll       = errors.New("invalid poll")
+	ErrInvalidPollOption = errors.New("invalid poll option")
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrQuizAnswered      = errors.New("quiz answer cannot be changed")
+	ErrPollAnonymous     = errors.New("poll voters are anonymous")
+)
+
+const (
+	// minPollOptions 投票最少的选项数
+	minPollOptions = 2
+	// maxPollOptions 投票最多的选项数
+	maxPollOptions = 10
+	// maxPollQuestionLength 投票问题的最大长度，与数据库字段长度一致
+	maxPollQuestionLength = 300
+	// maxPollOptionLength 投票选项的最大长度
+	maxPollOptionLength = 100
+	// pollCloseBatchSize 每轮最多结束的到期投票数
+	pollCloseBatchSize = 100
+)
+
+// PollRequest 发送投票消息时的投票内容
+type PollRequest struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	PublicVoters   bool       `json:"public_voters"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	IsQuiz         bool       `json:"is_quiz"`
+	CorrectOption  int        `json:"correct_option"`
+	CloseAt        *time.Time `json:"close_at"`
+}
+
+// validatePoll 校验投票内容
+func validatePoll(req *PollRequest) error {
+	if req == nil || strings.TrimSpace(req.Question) == "" || utf8.RuneCountInString(req.Question) > maxPollQuestionLength {
+		return ErrInvalidPoll
+	}
+	if len(req.Options) < minPollOptions || len(req.Options) > maxPollOptions {
+		return ErrInvalidPoll
+	}
+	for _, option := range req.Options {
+		if strings.TrimSpace(option) == "" || utf8.RuneCountInString(option) > maxPollOptionLength {
+			return ErrInvalidPoll
+		}
+	}
+	if req.IsQuiz && (req.MultipleChoice || req.CorrectOption < 0 || req.CorrectOption >= len(req.Options)) {
+		return ErrInvalidPoll
+	}
+	if req.CloseAt != nil && !req.CloseAt.After(time.Now()) {
+		return ErrInvalidPoll
+	}
+	return nil
+}
+
+// newPoll 根据已校验的请求生成投票
+func newPoll(req *PollRequest) *model.Poll {
+	poll := &model.Poll{
+		Question:       req.Question,
+		Options:        req.Options,
+		PublicVoters:   req.PublicVoters,
+		MultipleChoice: req.MultipleChoice,
+		IsQuiz:         req.IsQuiz,
+		CloseAt:        req.CloseAt,
+	}
+	if req.IsQuiz {
+		poll.CorrectOption = req.CorrectOption
+	}
+	return poll
+}
+
+// pollMessageID 投票所属的消息，转发的投票消息指向原消息
+func pollMessageID(message *model.Message) int64 {
+	if message.ForwardedFromMessageID != 0 {
+		return message.ForwardedFromMessageID
+	}
+	return message.ID
+}
+
+// fillPolls 为投票消息填充投票及当前结果
+func (s *MessageService) fillPolls(ctx context.Context, messages []*model.Message, userID int64) error {
+	messageIDs := make([]int64, 0)
+	for _, message := range messages {
+		if message.Type == 6 { // poll
+			messageIDs = append(messageIDs, pollMessageID(message))
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	polls, err := s.pollRepo.FindByMessageIDs(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+	if err := s.fillPollResults(ctx, polls, userID); err != nil {
+		return err
+	}
+
+	byMessage := make(map[int64]*model.Poll, len(polls))
+	for _, poll := range polls {
+		byMessage[poll.MessageID] = poll
+	}
+	for _, message := range messages {
+		if message.Type == 6 {
+			message.Poll = byMessage[pollMessageID(message)]
+		}
+	}
+	return nil
+}
+
+// fillPollResults 统计投票结果，并标记 userID 的选择
+// 测验的正确答案只在用户已作答或测验结束后返回
+func (s *MessageService) fillPollResults(ctx context.Context, polls []*model.Poll, userID int64) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	pollIDs := make([]int64, 0, len(polls))
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+	}
+
+	counts, err := s.pollRepo.FindOptionCounts(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	voters, err := s.pollRepo.FindVoterCounts(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	votes, err := s.pollRepo.FindUserVotes(ctx, pollIDs, userID)
+	if err != nil {
+		return err
+	}
+
+	byPoll := make(map[int64]*model.Poll, len(polls))
+	now := time.Now()
+	for _, poll := range polls {
+		poll.Results = make([]*model.PollOptionResult, len(poll.Options))
+		for i := range poll.Options {
+			poll.Results[i] = &model.PollOptionResult{Option: i}
+		}
+		poll.TotalVoters = 0
+		poll.Closed = poll.IsClosed(now)
+		poll.Correct = nil
+		byPoll[poll.ID] = poll
+	}
+	for _, count := range counts {
+		if poll := byPoll[count.PollID]; poll != nil && count.Option < len(poll.Results) {
+			poll.Results[count.Option].Voters = count.Count
+		}
+	}
+	for _, count := range voters {
+		if poll := byPoll[count.PollID]; poll != nil {
+			poll.TotalVoters = count.Count
+		}
+	}
+	voted := make(map[int64]bool)
+	for _, vote := range votes {
+		if poll := byPoll[vote.PollID]; poll != nil && vote.Option < len(poll.Results) {
+			poll.Results[vote.Option].Chosen = true
+			voted[poll.ID] = true
+		}
+	}
+	for _, poll := range polls {
+		if poll.IsQuiz && (voted[poll.ID] || poll.Closed) {
+			correct := poll.CorrectOption
+			poll.Correct = &correct
+		}
+	}
+	return nil
+}
+
+// PollUpdate poll_updated 事件的内容
+type PollUpdate struct {
+	ChatID    int64       `json:"chat_id"`
+	MessageID int64       `json:"message_id"`
+	Poll      *model.Poll `json:"poll"` // 变更后的结果，chosen 始终为 false
+}
+
+// PollService 投票服务
+type PollService struct {
+	pollRepo       *repository.PollRepository
+	messageService *MessageService
+	logger         *zap.Logger
+}
+
+func NewPollService(
+	pollRepo *repository.PollRepository,
+	messageService *MessageService,
+	logger *zap.Logger,
+) *PollService {
+	return &PollService{
+		pollRepo:       pollRepo,
+		messageService: messageService,
+		logger:         logger,
+	}
+}
+
+// Vote 投票，已投过票时替换之前的选择；测验只能作答一次
+// 返回当前用户视角的投票结果
+func (s *PollService) Vote(ctx context.Context, messageID, userID int64, options []int) (*model.Poll, error) {
+	message, poll, err := s.openPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options) == 0 || (len(options) > 1 && !poll.MultipleChoice) {
+		return nil, ErrInvalidPollOption
+	}
+	seen := make(map[int]bool, len(options))
+	for _, option := range options {
+		if option < 0 || option >= len(poll.Options) || seen[option] {
+			return nil, ErrInvalidPollOption
+		}
+		seen[option] = true
+	}
+
+	// 测验只能作答一次，检查和保存在同一个事务中完成
+	if poll.IsQuiz {
+		answered, err := s.pollRepo.AnswerQuiz(ctx, poll.ID, userID, options)
+		if err != nil {
+			s.logger.Error("failed to answer quiz", zap.Error(err))
+			return nil, err
+		}
+		if !answered {
+			return nil, ErrQuizAnswered
+		}
+	} else if err := s.pollRepo.ReplaceVotes(ctx, poll.ID, userID, options); err != nil {
+		s.logger.Error("failed to vote", zap.Error(err))
+		return nil, err
+	}
+
+	return s.pollChanged(ctx, message, poll, userID)
+}
+
+// RetractVote 撤回投票，测验的答案不能撤回
+func (s *PollService) RetractVote(ctx context.Context, messageID, userID int64) (*model.Poll, error) {
+	message, poll, err := s.openPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.IsQuiz {
+		return nil, ErrQuizAnswered
+	}
+
+	retracted, err := s.pollRepo.DeleteVotes(ctx, poll.ID, userID)
+	if err != nil {
+		s.logger.Error("failed to retract vote", zap.Error(err))
+		return nil, err
+	}
+	if !retracted {
+		if err := s.messageService.fillPollResults(ctx, []*model.Poll{poll}, userID); err != nil {
+			return nil, err
+		}
+		return poll, nil
+	}
+
+	return s.pollChanged(ctx, message, poll, userID)
+}
+
+// ClosePoll 结束投票，只有投票的发送者可以操作
+func (s *PollService) ClosePoll(ctx context.Context, messageID, userID int64) (*model.Poll, error) {
+	message, poll, err := s.memberPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.ID != poll.MessageID || message.SenderID != userID {
+		return nil, ErrNotAuthorized
+	}
+	if poll.IsClosed(time.Now()) {
+		return nil, ErrPollClosed
+	}
+
+	now := time.Now()
+	if err := s.pollRepo.Close(ctx, poll.ID, now); err != nil {
+		s.logger.Error("failed to close poll", zap.Error(err))
+		return nil, err
+	}
+	poll.ClosedAt = &now
+
+	return s.pollChanged(ctx, message, poll, userID)
+}
+
+// Run 定期结束到期的投票并推送 poll_updated 事件，与手动结束一致，直到 ctx 结束
+func (s *PollService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.closeExpired(ctx)
+		}
+	}
+}
+
+// closeExpired 结束一批到期的投票
+func (s *PollService) closeExpired(ctx context.Context) {
+	polls, err := s.pollRepo.FindExpired(ctx, time.Now(), pollCloseBatchSize)
+	if err != nil {
+		s.logger.Error("failed to find expired polls", zap.Error(err))
+		return
+	}
+
+	for _, poll := range polls {
+		closed, err := s.pollRepo.CloseExpired(ctx, poll.ID)
+		if err != nil {
+			s.logger.Error("failed to close expired poll", zap.Int64("id", poll.ID), zap.Error(err))
+			continue
+		}
+		if !closed {
+			continue // 已被发送者结束或已被其他节点处理
+		}
+		poll.ClosedAt = poll.CloseAt
+
+		// 推送给投票所在的聊天室，结果不包含任何用户的选择
+		message := &model.Message{ID: poll.MessageID, ChatID: poll.ChatID}
+		if _, err := s.pollChanged(ctx, message, poll, 0); err != nil {
+			s.logger.Error("failed to notify closed poll", zap.Int64("id", poll.ID), zap.Error(err))
+		}
+	}
+}
+
+// PollVoters 选择了某个选项的用户
+type PollVoters struct {
+	Option  int     `json:"option"`
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// GetVoters 分页获取选择了某个选项的用户，仅公开投票可以查询
+func (s *PollService) GetVoters(ctx context.Context, messageID, userID int64, option, offset, limit int) (*PollVoters, error) {
+	_, poll, err := s.memberPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !poll.PublicVoters {
+		return nil, ErrPollAnonymous
+	}
+	if option < 0 || option >= len(poll.Options) {
+		return nil, ErrInvalidPollOption
+	}
+
+	userIDs, err := s.pollRepo.FindVoters(ctx, poll.ID, option, offset, limit)
+	if err != nil {
+		s.logger.Error("failed to get poll voters", zap.Error(err))
+		return nil, err
+	}
+	return &PollVoters{Option: option, UserIDs: userIDs}, nil
+}
+
+// memberPoll 查询消息对应的投票，并校验用户是该聊天室成员
+func (s *PollService) memberPoll(ctx context.Context, messageID, userID int64) (*model.Message, *model.Poll, error) {
+	message, err := s.messageService.memberMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.Type != 6 { // poll
+		return nil, nil, ErrPollNotFound
+	}
+
+	poll, err := s.pollRepo.FindByMessageID(ctx, pollMessageID(message))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPollNotFound
+		}
+		return nil, nil, err
+	}
+	return message, poll, nil
+}
+
+// openPoll 查询未结束的投票
+func (s *PollService) openPoll(ctx context.Context, messageID, userID int64) (*model.Message, *model.Poll, error) {
+	message, poll, err := s.memberPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if poll.IsClosed(time.Now()) {
+		return nil, nil, ErrPollClosed
+	}
+	return message, poll, nil
+}
+
+// pollChanged 重新统计投票结果并推送 poll_updated 事件
+// 在转发的消息上投票时，原消息所在的聊天室也会收到更新
+func (s *PollService) pollChanged(ctx context.Context, message *model.Message, poll *model.Poll, userID int64) (*model.Poll, error) {
+	if err := s.messageService.fillPollResults(ctx, []*model.Poll{poll}, userID); err != nil {
+		return nil, err
+	}
+
+	// chosen 和测验答案只对当前用户有意义，推送给其他成员时清除
+	shared := *poll
+	shared.Results = make([]*model.PollOptionResult, 0, len(poll.Results))
+	for _, result := range poll.Results {
+		shared.Results = append(shared.Results, &model.PollOptionResult{Option: result.Option, Voters: result.Voters})
+	}
+	if !shared.Closed {
+		shared.Correct = nil
+	}
+
+	s.messageService.emitChatEvent(message.ChatID, EventPollUpdated, &PollUpdate{
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		Poll:      &shared,
+	})
+	if poll.ChatID != message.ChatID {
+		s.messageService.emitChatEvent(poll.ChatID, EventPollUpdated, &PollUpdate{
+			ChatID:    poll.ChatID,
+			MessageID: poll.MessageID,
+			Poll:      &shared,
+		})
+	}
+
+	return poll, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+)
+
+func TestValidatePoll(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		req  *PollRequest
+		err  error
+	}{
+		{"missing poll", nil, ErrInvalidPoll},
+		{"valid poll", &PollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}}, nil},
+		{"blank question", &PollRequest{Question: " ", Options: []string{"A", "B"}}, ErrInvalidPoll},
+		{"one option", &PollRequest{Question: "Q", Options: []string{"A"}}, ErrInvalidPoll},
+		{"blank option", &PollRequest{Question: "Q", Options: []string{"A", ""}}, ErrInvalidPoll},
+		{"question too long", &PollRequest{Question: strings.Repeat("Q", 301), Options: []string{"A", "B"}}, ErrInvalidPoll},
+		{"option too long", &PollRequest{Question: "Q", Options: []string{"A", strings.Repeat("B", 101)}}, ErrInvalidPoll},
+		{"valid quiz", &PollRequest{Question: "Q", Options: []string{"A", "B"}, IsQuiz: true, CorrectOption: 1}, nil},
+		{"quiz answer out of range", &PollRequest{Question: "Q", Options: []string{"A", "B"}, IsQuiz: true, CorrectOption: 2}, ErrInvalidPoll},
+		{"multiple choice quiz", &PollRequest{Question: "Q", Options: []string{"A", "B"}, IsQuiz: true, MultipleChoice: true}, ErrInvalidPoll},
+		{"close time in the past", &PollRequest{Question: "Q", Options: []string{"A", "B"}, CloseAt: &past}, ErrInvalidPoll},
+		{"close time in the future", &PollRequest{Question: "Q", Options: []string{"A", "B"}, CloseAt: &future}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, validatePoll(tt.req))
+		})
+	}
+}
+
+// chatEvent 推送给聊天室的事件
+type chatEvent struct {
+	chatID    int64
+	eventType string
+	payload   interface{}
+}
+
+// recordingEvents 记录推送的事件
+type recordingEvents struct {
+	chatEvents []chatEvent
+}
+
+func (e *recordingEvents) OnChatEvent(chatID int64, eventType string, payload interface{}) {
+	e.chatEvents = append(e.chatEvents, chatEvent{chatID: chatID, eventType: eventType, payload: payload})
+}
+
+func (e *recordingEvents) OnUserEvent(int64, string, interface{}) {}
+
+func TestCloseExpired_NotifiesChat(t *testing.T) {
+	fake, db := testutil.NewFakeDB(t)
+	logger := zap.NewNop()
+	pollRepo := repository.NewPollRepository(db)
+	messageService := NewMessageService(
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		pollRepo,
+		repository.NewUserRepository(db),
+		logger,
+	)
+	events := &recordingEvents{}
+	messageService.SetEventBroadcaster(events)
+	pollService := NewPollService(pollRepo, messageService, logger)
+
+	closeAt := time.Now().Add(-time.Second)
+	fake.On("closed_at IS NULL AND close_at <= ?", &testutil.Rows{
+		Columns: []string{"id", "message_id", "chat_id", "question", "options", "is_quiz", "correct_option", "close_at"},
+		Values:  [][]driver.Value{{int64(9), int64(5), int64(7), "2+2?", []byte(`["3","4"]`), true, int64(1), closeAt}},
+	})
+	fake.On("COUNT(*)", &testutil.Rows{
+		Columns: []string{"poll_id", "option", "count"},
+		Values:  [][]driver.Value{{int64(9), int64(1), int64(3)}},
+	})
+	fake.On("COUNT(DISTINCT user_id)", &testutil.Rows{
+		Columns: []string{"poll_id", "count"},
+		Values:  [][]driver.Value{{int64(9), int64(3)}},
+	})
+
+	pollService.closeExpired(context.Background())
+
+	closes := fake.Executed("UPDATE `polls`")
+	require.Len(t, closes, 1)
+	assert.Contains(t, closes[0].SQL, "`closed_at`=close_at")
+
+	require.Len(t, events.chatEvents, 1)
+	event := events.chatEvents[0]
+	assert.Equal(t, int64(7), event.chatID)
+	assert.Equal(t, EventPollUpdated, event.eventType)
+	update := event.payload.(*PollUpdate)
+	assert.Equal(t, int64(5), update.MessageID)
+	assert.True(t, update.Poll.Closed)
+	assert.Equal(t, 3, update.Poll.Results[1].Voters)
+	// 测验结束后公开正确答案
+	require.NotNil(t, update.Poll.Correct)
+	assert.Equal(t, 1, *update.Poll.Correct)
+}
+
+func TestVote_QuizIsAnsweredOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		answered int64 // 已保存的答案数
+		err      error
+		inserted bool
+	}{
+		{name: "first answer", answered: 0, inserted: true},
+		{name: "already answered", answered: 1, err: ErrQuizAnswered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := newChatFixture(t, 2, testMember(11, RoleOwner, 0), testMember(12, RoleMember, 0))
+			fake.On("FROM `messages`", &testutil.Rows{
+				Columns: []string{"id", "chat_id", "sender_id", "type", "content"},
+				Values:  [][]driver.Value{{int64(testMessageID), int64(testChatID), int64(11), int64(6), "2+2?"}},
+			})
+			fake.On("SELECT count(*) FROM `poll_votes`", &testutil.Rows{
+				Columns: []string{"count"},
+				Values:  [][]driver.Value{{tt.answered}},
+			})
+			fake.On("FROM `polls`", &testutil.Rows{
+				Columns: []string{"id", "message_id", "chat_id", "question", "options", "is_quiz", "correct_option"},
+				Values:  [][]driver.Value{{int64(9), int64(testMessageID), int64(testChatID), "2+2?", []byte(`["3","4"]`), true, int64(1)}},
+			})
+			logger := zap.NewNop()
+			pollRepo := repository.NewPollRepository(db)
+			messageService := NewMessageService(
+				repository.NewMessageRepository(db),
+				repository.NewChatRepository(db),
+				pollRepo,
+				repository.NewUserRepository(db),
+				logger,
+			)
+			pollService := NewPollService(pollRepo, messageService, logger)
+
+			_, err := pollService.Vote(context.Background(), testMessageID, 12, []int{0})
+			assert.Equal(t, tt.err, err)
+
+			// 是否已作答在锁定投票后检查，与保存答案在同一个事务中
+			locks := fake.Executed("FOR UPDATE")
+			require.Len(t, locks, 1)
+			assert.Contains(t, locks[0].SQL, "FROM `polls`")
+			assert.Equal(t, tt.inserted, len(fake.Executed("INSERT INTO `poll_votes`")) == 1)
+			assert.Empty(t, fake.Executed("DELETE FROM `poll_votes`"))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUserBanned          = errors.New("user is banned from this chat")
+	ErrMuted               = errors.New("user is muted in this chat")
+	ErrMediaRestricted     = errors.New("user cannot send media in this chat")
+	ErrInvalidRestriction  = errors.New("invalid restriction")
+	ErrRestrictionNotFound = errors.New("restriction not found")
+)
+
+// RestrictRequest 限制成员请求
+type RestrictRequest struct {
+	Banned     bool       `json:"banned"`
+	MutedUntil *time.Time `json:"muted_until"` // 禁言到期时间，为空表示不禁言
+	NoMedia    bool       `json:"no_media"`
+}
+
+// isMediaType 图片、文件和语音消息
+func isMediaType(msgType int) bool {
+	return msgType == 2 || msgType == 3 || msgType == 4
+}
+
+// checkBanned 用户被聊天室封禁时返回 ErrUserBanned
+func checkBanned(ctx context.Context, chatRepo *repository.ChatRepository, chatID, userID int64) error {
+	restriction, err := chatRepo.GetRestriction(ctx, chatID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if restriction.Banned {
+		return ErrUserBanned
+	}
+	return nil
+}
+
+// checkRestricted 校验成员能否发送指定类型的消息，禁言中返回 ErrMuted，禁止发送媒体时返回 ErrMediaRestricted
+// 限制只对普通成员生效
+func checkRestricted(ctx context.Context, chatRepo *repository.ChatRepository, member *model.ChatMember, msgTypes ...int) error {
+	if member.Role != RoleMember {
+		return nil
+	}
+	restriction, err := chatRepo.GetRestriction(ctx, member.ChatID, member.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if restriction.IsMuted(time.Now()) {
+		return ErrMuted
+	}
+	if restriction.NoMedia {
+		for _, msgType := range msgTypes {
+			if isMediaType(msgType) {
+				return ErrMediaRestricted
+			}
+		}
+	}
+	return nil
+}
+
+// RestrictMember 封禁、禁言或限制成员发送媒体，覆盖已有的限制；需要移除权限
+// 只能限制普通成员和非成员，管理员需要先撤销；封禁成员时同时把其移出聊天室
+func (s *ChatService) RestrictMember(ctx context.Context, chatID, actorID, userID int64, req *RestrictRequest) (*model.ChatRestriction, error) {
+	if !req.Banned && !req.NoMedia && req.MutedUntil == nil {
+		return nil, ErrInvalidRestriction
+	}
+	if req.MutedUntil != nil && !req.MutedUntil.After(time.Now()) {
+		return nil, ErrInvalidRestriction
+	}
+
+	chat, actor, err := requireRight(ctx, s.chatRepo, chatID, actorID, RightKick)
+	if err != nil {
+		return nil, err
+	}
+	if actorID == userID {
+		return nil, ErrNotAuthorized
+	}
+
+	target, err := s.chatRepo.GetMember(ctx, chatID, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if target != nil && (target.Role != RoleMember || !canManageMember(chat, actor, target, RightKick)) {
+		return nil, ErrNotAuthorized
+	}
+	if target == nil {
+		if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrUserNotFound
+			}
+			return nil, err
+		}
+	}
+
+	restriction := &model.ChatRestriction{
+		ChatID:     chatID,
+		UserID:     userID,
+		Banned:     req.Banned,
+		MutedUntil: req.MutedUntil,
+		NoMedia:    req.NoMedia,
+		CreatorID:  actorID,
+	}
+	if err := s.chatRepo.SaveRestriction(ctx, restriction); err != nil {
+		s.logger.Error("failed to save restriction", zap.Error(err))
+		return nil, err
+	}
+
+	if req.Banned && target != nil {
+		if err := s.chatRepo.RemoveMember(ctx, chatID, userID); err != nil {
+			return nil, err
+		}
+		s.notifyMemberRemoved(chatID, userID)
+	}
+	return restriction, nil
+}
+
+// LiftRestriction 解除成员的全部限制，被封禁的用户可以重新加入；需要移除权限
+func (s *ChatService) LiftRestriction(ctx context.Context, chatID, actorID, userID int64) error {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, actorID, RightKick); err != nil {
+		return err
+	}
+
+	deleted, err := s.chatRepo.DeleteRestriction(ctx, chatID, userID)
+	if err != nil {
+		s.logger.Error("failed to delete restriction", zap.Error(err))
+		return err
+	}
+	if !deleted {
+		return ErrRestrictionNotFound
+	}
+	return nil
+}
+
+// GetRestrictions 获取聊天室中仍然有效的限制，需要移除权限
+func (s *ChatService) GetRestrictions(ctx context.Context, chatID, userID int64) ([]*model.ChatRestriction, error) {
+	if _, _, err := requireRight(ctx, s.chatRepo, chatID, userID, RightKick); err != nil {
+		return nil, err
+	}
+	return s.chatRepo.GetRestrictions(ctx, chatID, time.Now())
+}
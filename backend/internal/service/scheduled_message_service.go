@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrScheduledNotFound = errors.New("scheduled message not found or already sent")
+	ErrInvalidSendAt     = errors.New("send_at must be in the future")
+)
+
+const (
+	// schedulerBatchSize 每轮最多发送的定时消息数
+	schedulerBatchSize = 100
+	// schedulerClaimTimeout 领取后超过该时间仍未完成的消息会被重新领取（例如节点在发送中途重启）
+	schedulerClaimTimeout = time.Minute
+)
+
+// ScheduledMessageService 定时消息服务
+// 到期的消息由 Run 领取后通过 MessageService.SendMessage 发送，与普通消息一样广播和离线推送
+type ScheduledMessageService struct {
+	scheduledRepo  *repository.ScheduledMessageRepository
+	messageService *MessageService
+	logger         *zap.Logger
+}
+
+func NewScheduledMessageService(
+	scheduledRepo *repository.ScheduledMessageRepository,
+	messageService *MessageService,
+	logger *zap.Logger,
+) *ScheduledMessageService {
+	return &ScheduledMessageService{
+		scheduledRepo:  scheduledRepo,
+		messageService: messageService,
+		logger:         logger,
+	}
+}
+
+// Schedule 创建定时消息，校验规则与立即发送相同
+func (s *ScheduledMessageService) Schedule(ctx context.Context, senderID int64, req *SendMessageRequest, sendAt time.Time) (*model.ScheduledMessage, error) {
+	if !sendAt.After(time.Now()) {
+		return nil, ErrInvalidSendAt
+	}
+
+	if _, _, err := s.messageService.checkSend(ctx, senderID, req); err != nil {
+		return nil, err
+	}
+	if req.Type == 6 { // 定时消息不保存投票内容，投票只能立即发送
+		return nil, ErrInvalidPoll
+	}
+
+	message := &model.ScheduledMessage{
+		ChatID:    req.ChatID,
+		SenderID:  senderID,
+		Type:      req.Type,
+		Content:   req.Content,
+		MediaURL:  req.MediaURL,
+		Duration:  req.Duration,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		ReplyID:   req.ReplyID,
+		TTL:       req.TTL,
+		SendAt:    sendAt,
+		Status:    model.ScheduledPending,
+	}
+	if err := s.scheduledRepo.Create(ctx, message); err != nil {
+		s.logger.Error("failed to create scheduled message", zap.Error(err))
+		return nil, err
+	}
+	return message, nil
+}
+
+// GetPending 获取自己等待发送的定时消息，chatID 为 0 时返回所有聊天室的
+func (s *ScheduledMessageService) GetPending(ctx context.Context, senderID, chatID int64) ([]*model.ScheduledMessage, error) {
+	return s.scheduledRepo.FindPending(ctx, senderID, chatID)
+}
+
+// UpdateScheduledRequest 修改定时消息请求，为空的字段不修改
+type UpdateScheduledRequest struct {
+	Content *string    `json:"content"`
+	SendAt  *time.Time `json:"send_at"`
+}
+
+// Update 修改等待发送的定时消息，新内容与创建时一样经过 checkSend 校验
+func (s *ScheduledMessageService) Update(ctx context.Context, id, senderID int64, req *UpdateScheduledRequest) (*model.ScheduledMessage, error) {
+	updates := make(map[string]interface{})
+	if req.Content != nil {
+		if err := s.checkContent(ctx, id, senderID, *req.Content); err != nil {
+			return nil, err
+		}
+		updates["content"] = *req.Content
+	}
+	if req.SendAt != nil {
+		if !req.SendAt.After(time.Now()) {
+			return nil, ErrInvalidSendAt
+		}
+		updates["send_at"] = *req.SendAt
+	}
+
+	if len(updates) > 0 {
+		updated, err := s.scheduledRepo.UpdatePending(ctx, id, senderID, updates)
+		if err != nil {
+			s.logger.Error("failed to update scheduled message", zap.Error(err))
+			return nil, err
+		}
+		if !updated {
+			return nil, ErrScheduledNotFound
+		}
+	}
+
+	message, err := s.scheduledRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduledNotFound
+		}
+		return nil, err
+	}
+	if message.SenderID != senderID || message.Status != model.ScheduledPending {
+		return nil, ErrScheduledNotFound
+	}
+	return message, nil
+}
+
+// checkContent 用新内容替换后按立即发送的规则校验定时消息
+func (s *ScheduledMessageService) checkContent(ctx context.Context, id, senderID int64, content string) error {
+	message, err := s.scheduledRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrScheduledNotFound
+		}
+		return err
+	}
+	if message.SenderID != senderID || message.Status != model.ScheduledPending {
+		return ErrScheduledNotFound
+	}
+
+	_, _, err = s.messageService.checkSend(ctx, senderID, &SendMessageRequest{
+		ChatID:    message.ChatID,
+		Type:      message.Type,
+		Content:   content,
+		MediaURL:  message.MediaURL,
+		Duration:  message.Duration,
+		Latitude:  message.Latitude,
+		Longitude: message.Longitude,
+		ReplyID:   message.ReplyID,
+		TTL:       message.TTL,
+	})
+	return err
+}
+
+// Cancel 取消等待发送的定时消息
+func (s *ScheduledMessageService) Cancel(ctx context.Context, id, senderID int64) error {
+	cancelled, err := s.scheduledRepo.UpdatePending(ctx, id, senderID, map[string]interface{}{
+		"status": model.ScheduledCancelled,
+	})
+	if err != nil {
+		s.logger.Error("failed to cancel scheduled message", zap.Error(err))
+		return err
+	}
+	if !cancelled {
+		return ErrScheduledNotFound
+	}
+	return nil
+}
+
+// Run 定期发送到期的定时消息，直到 ctx 结束
+func (s *ScheduledMessageService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.dispatchDue(ctx)
+		}
+	}
+}
+
+// dispatchDue 领取并发送一批到期的定时消息
+func (s *ScheduledMessageService) dispatchDue(ctx context.Context) {
+	now := time.Now()
+	staleBefore := now.Add(-schedulerClaimTimeout)
+
+	due, err := s.scheduledRepo.FindDue(ctx, now, staleBefore, schedulerBatchSize)
+	if err != nil {
+		s.logger.Error("failed to find due scheduled messages", zap.Error(err))
+		return
+	}
+
+	for _, scheduled := range due {
+		claimed, err := s.scheduledRepo.Claim(ctx, scheduled.ID, now, staleBefore)
+		if err != nil {
+			s.logger.Error("failed to claim scheduled message", zap.Int64("id", scheduled.ID), zap.Error(err))
+			continue
+		}
+		if !claimed {
+			continue // 已被其他节点领取
+		}
+		s.send(ctx, scheduled)
+	}
+}
+
+// send 发送已领取的定时消息
+// 使用由定时消息ID生成的 ClientMsgID，节点在发送后、标记完成前重启时，重新领取也不会重复发送
+// 该前缀为定时消息保留，客户端提交的 ClientMsgID 不能使用
+func (s *ScheduledMessageService) send(ctx context.Context, scheduled *model.ScheduledMessage) {
+	message, err := s.messageService.SendMessage(ctx, scheduled.SenderID, &SendMessageRequest{
+		ChatID:      scheduled.ChatID,
+		Type:        scheduled.Type,
+		Content:     scheduled.Content,
+		MediaURL:    scheduled.MediaURL,
+		Duration:    scheduled.Duration,
+		Latitude:    scheduled.Latitude,
+		Longitude:   scheduled.Longitude,
+		ReplyID:     scheduled.ReplyID,
+		TTL:         scheduled.TTL,
+		ClientMsgID: scheduledClientMsgIDPrefix + strconv.FormatInt(scheduled.ID, 10),
+		scheduled:   true,
+	})
+	if err != nil {
+		if isSendRejected(err) {
+			s.logger.Warn("scheduled message rejected", zap.Int64("id", scheduled.ID), zap.Error(err))
+			if err := s.scheduledRepo.Finish(ctx, scheduled.ID, model.ScheduledFailed, 0); err != nil {
+				s.logger.Error("failed to mark scheduled message failed", zap.Error(err))
+			}
+			return
+		}
+		// 其他错误保持领取状态，超时后重新领取
+		s.logger.Error("failed to send scheduled message", zap.Int64("id", scheduled.ID), zap.Error(err))
+		return
+	}
+
+	if err := s.scheduledRepo.Finish(ctx, scheduled.ID, model.ScheduledSent, message.ID); err != nil {
+		s.logger.Error("failed to mark scheduled message sent", zap.Error(err))
+	}
+}
+
+// isSendRejected 判断是否为重试也无法成功的业务错误，例如发送者已离开聊天室、被禁言或被封禁
+// ErrorCode 映射为 4xx 的错误都是业务错误，其他错误（如数据库错误）可能只是暂时的
+func isSendRejected(err error) bool {
+	code, _ := ErrorCode(err)
+	return code < 500
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+	"github.com/forever-free1/telegram-go/backend/pkg/snowflake"
+)
+
+func TestIsSendRejected(t *testing.T) {
+	assert.True(t, isSendRejected(ErrNotAuthorized))
+	assert.True(t, isSendRejected(ErrMuted))
+	assert.True(t, isSendRejected(ErrMediaRestricted))
+	assert.True(t, isSendRejected(ErrUserBanned))
+	assert.False(t, isSendRejected(driver.ErrBadConn))
+}
+
+// newTestScheduler 创建使用假数据库的定时消息服务，到期的定时消息由用户 2 在群聊 7 中发送
+func newTestScheduler(t *testing.T, ttl int) (*testutil.FakeDB, *ScheduledMessageService) {
+	_, err := snowflake.NewSnowflake(1)
+	require.NoError(t, err)
+	fake, db := testutil.NewFakeDB(t)
+	logger := zap.NewNop()
+	messageService := NewMessageService(
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		repository.NewPollRepository(db),
+		repository.NewUserRepository(db),
+		logger,
+	)
+	scheduledService := NewScheduledMessageService(repository.NewScheduledMessageRepository(db), messageService, logger)
+
+	fake.On("FROM `scheduled_messages`", &testutil.Rows{
+		Columns: []string{"id", "chat_id", "sender_id", "type", "content", "ttl", "status"},
+		Values:  [][]driver.Value{{int64(1), int64(7), int64(2), int64(1), "hi", int64(ttl), int64(model.ScheduledPending)}},
+	})
+	fake.On("FROM `chats`", &testutil.Rows{
+		Columns: []string{"id", "type"},
+		Values:  [][]driver.Value{{int64(7), int64(2)}},
+	})
+	fake.On("FROM `chat_members`", &testutil.Rows{
+		Columns: []string{"chat_id", "user_id", "role"},
+		Values:  [][]driver.Value{{int64(7), int64(2), int64(RoleMember)}},
+	})
+	return fake, scheduledService
+}
+
+func TestDispatchDue_SendsWithTTL(t *testing.T) {
+	fake, scheduledService := newTestScheduler(t, 30)
+
+	scheduledService.dispatchDue(context.Background())
+
+	inserts := fake.Executed("INSERT INTO `messages`")
+	require.Len(t, inserts, 1)
+	assert.Contains(t, inserts[0].SQL, "`ttl`")
+	assert.Contains(t, inserts[0].Args, driver.Value(int64(30)))
+	// 定时消息自己可以使用客户端不能使用的保留前缀
+	assert.Contains(t, inserts[0].Args, driver.Value(scheduledClientMsgIDPrefix+"1"))
+
+	updates := fake.Executed("UPDATE `scheduled_messages`")
+	require.Len(t, updates, 2)
+	assert.Contains(t, updates[1].Args, driver.Value(int64(model.ScheduledSent)))
+}
+
+func TestDispatchDue_FailsWhenSenderIsMuted(t *testing.T) {
+	fake, scheduledService := newTestScheduler(t, 0)
+	// 发送者在定时消息创建后被禁言
+	fake.On("FROM `chat_restrictions`", &testutil.Rows{
+		Columns: []string{"chat_id", "user_id", "muted_until"},
+		Values:  [][]driver.Value{{int64(7), int64(2), time.Now().Add(time.Hour)}},
+	})
+
+	scheduledService.dispatchDue(context.Background())
+
+	// 领取后直接标记为发送失败，不会保持领取状态等待重试
+	updates := fake.Executed("UPDATE `scheduled_messages`")
+	require.Len(t, updates, 2)
+	assert.Contains(t, updates[1].Args, driver.Value(int64(model.ScheduledFailed)))
+	assert.Empty(t, fake.Executed("INSERT INTO `messages`"))
+}
+
+func TestUpdate_ValidatesNewContent(t *testing.T) {
+	content := "updated"
+
+	t.Run("valid", func(t *testing.T) {
+		fake, scheduledService := newTestScheduler(t, 0)
+
+		_, err := scheduledService.Update(context.Background(), 1, 2, &UpdateScheduledRequest{Content: &content})
+		require.NoError(t, err)
+		updates := fake.Executed("UPDATE `scheduled_messages`")
+		require.Len(t, updates, 1)
+		assert.Contains(t, updates[0].Args, driver.Value(content))
+	})
+
+	t.Run("sender muted since scheduling", func(t *testing.T) {
+		fake, scheduledService := newTestScheduler(t, 0)
+		fake.On("FROM `chat_restrictions`", &testutil.Rows{
+			Columns: []string{"chat_id", "user_id", "muted_until"},
+			Values:  [][]driver.Value{{int64(7), int64(2), time.Now().Add(time.Hour)}},
+		})
+
+		_, err := scheduledService.Update(context.Background(), 1, 2, &UpdateScheduledRequest{Content: &content})
+		assert.ErrorIs(t, err, ErrMuted)
+		assert.Empty(t, fake.Executed("UPDATE `scheduled_messages`"))
+	})
+
+	t.Run("not the sender", func(t *testing.T) {
+		fake, scheduledService := newTestScheduler(t, 0)
+
+		_, err := scheduledService.Update(context.Background(), 1, 3, &UpdateScheduledRequest{Content: &content})
+		assert.ErrorIs(t, err, ErrScheduledNotFound)
+		assert.Empty(t, fake.Executed("UPDATE `scheduled_messages`"))
+	})
+}
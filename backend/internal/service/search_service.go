@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/search"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchService 消息搜索服务，只搜索用户所在聊天室的消息
+type SearchService struct {
+	index       search.Index
+	chatRepo    *repository.ChatRepository
+	messageRepo *repository.MessageRepository
+	logger      *zap.Logger
+}
+
+func NewSearchService(
+	index search.Index,
+	chatRepo *repository.ChatRepository,
+	messageRepo *repository.MessageRepository,
+	logger *zap.Logger,
+) *SearchService {
+	return &SearchService{
+		index:       index,
+		chatRepo:    chatRepo,
+		messageRepo: messageRepo,
+		logger:      logger,
+	}
+}
+
+// SearchMessagesRequest 搜索消息请求，零值的过滤条件不生效
+type SearchMessagesRequest struct {
+	Query    string    `json:"query"`
+	ChatID   int64     `json:"chat_id"`
+	SenderID int64     `json:"sender_id"`
+	Type     int       `json:"type"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Offset   int       `json:"offset"`
+	Limit    int       `json:"limit"`
+}
+
+// SearchHit 一条搜索结果
+type SearchHit struct {
+	Message *model.Message `json:"message"`
+	Snippet string         `json:"snippet"` // 高亮的匹配片段，匹配词用 <mark></mark> 包裹
+	Score   float64        `json:"score"`
+}
+
+// SearchResult 搜索结果，按相关度排序
+type SearchResult struct {
+	Hits    []*SearchHit `json:"hits"`
+	Total   int64        `json:"total"`
+	HasMore bool         `json:"has_more"`
+}
+
+// Search 搜索用户所在聊天室的消息，指定 ChatID 时只搜索该聊天室
+func (s *SearchService) Search(ctx context.Context, userID int64, req *SearchMessagesRequest) (*SearchResult, error) {
+	chatIDs, err := s.chatRepo.GetChatIDsByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to get user chat IDs", zap.Error(err))
+		return nil, err
+	}
+	if req.ChatID != 0 {
+		member := false
+		for _, chatID := range chatIDs {
+			if chatID == req.ChatID {
+				member = true
+				break
+			}
+		}
+		if !member {
+			return nil, ErrNotAuthorized
+		}
+		chatIDs = []int64{req.ChatID}
+	}
+
+	empty := &SearchResult{Hits: []*SearchHit{}}
+	if len(chatIDs) == 0 || len(search.Tokenize(req.Query)) == 0 {
+		return empty, nil
+	}
+
+	offset, limit := req.Offset, req.Limit
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	} else if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	result, err := s.index.Search(ctx, &search.Query{
+		Text:     req.Query,
+		UserID:   userID,
+		ChatIDs:  chatIDs,
+		SenderID: req.SenderID,
+		Type:     req.Type,
+		From:     req.From,
+		To:       req.To,
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		s.logger.Error("failed to search messages", zap.Error(err))
+		return nil, err
+	}
+	if len(result.Hits) == 0 {
+		empty.Total = result.Total
+		return empty, nil
+	}
+
+	messageIDs := make([]int64, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		messageIDs = append(messageIDs, hit.MessageID)
+	}
+	messages, err := s.messageRepo.FindByIDs(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*model.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+
+	// 按索引返回的相关度顺序返回，索引更新滞后时跳过已删除的消息
+	hits := make([]*SearchHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		message := byID[hit.MessageID]
+		if message == nil || message.IsDeleted {
+			continue
+		}
+		hits = append(hits, &SearchHit{Message: message, Snippet: hit.Snippet, Score: hit.Score})
+	}
+
+	return &SearchResult{
+		Hits:    hits,
+		Total:   result.Total,
+		HasMore: int64(offset+len(result.Hits)) < result.Total,
+	}, nil
+}
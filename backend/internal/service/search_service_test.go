@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/repository"
+	"github.com/forever-free1/telegram-go/backend/internal/search"
+	"github.com/forever-free1/telegram-go/backend/internal/testutil"
+)
+
+// recordingIndex 记录收到的搜索条件，不返回任何结果
+type recordingIndex struct {
+	queries []*search.Query
+}
+
+func (i *recordingIndex) Index(context.Context, *model.Message) error   { return nil }
+func (i *recordingIndex) Remove(context.Context, int64) error           { return nil }
+func (i *recordingIndex) RemoveFor(context.Context, int64, int64) error { return nil }
+
+func (i *recordingIndex) Search(_ context.Context, query *search.Query) (*search.Result, error) {
+	i.queries = append(i.queries, query)
+	return &search.Result{}, nil
+}
+
+func TestSearchService_ClampsPaging(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		limit  int
+		want   search.Query
+	}{
+		{name: "negative limit", limit: -1, want: search.Query{Limit: defaultSearchLimit}},
+		{name: "zero limit", limit: 0, want: search.Query{Limit: defaultSearchLimit}},
+		{name: "limit above max", limit: 1000, want: search.Query{Limit: maxSearchLimit}},
+		{name: "negative offset", offset: -5, limit: 10, want: search.Query{Limit: 10}},
+		{name: "in range", offset: 40, limit: 10, want: search.Query{Offset: 40, Limit: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := testutil.NewFakeDB(t)
+			fake.On("FROM `chat_members`", &testutil.Rows{Columns: []string{"chat_id"}, Values: [][]driver.Value{{int64(testChatID)}}})
+			index := &recordingIndex{}
+			svc := NewSearchService(index, repository.NewChatRepository(db), repository.NewMessageRepository(db), zap.NewNop())
+
+			_, err := svc.Search(context.Background(), 11, &SearchMessagesRequest{Query: "hello", Offset: tt.offset, Limit: tt.limit})
+			require.NoError(t, err)
+			require.Len(t, index.queries, 1)
+			assert.Equal(t, tt.want.Offset, index.queries[0].Offset)
+			assert.Equal(t, tt.want.Limit, index.queries[0].Limit)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Package testutil 测试辅助工具
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// FakeDB 不依赖 MySQL 的假数据库，用于在测试中驱动仓储层
+// 查询按 SQL 中包含的片段返回预设的结果，没有匹配时返回空结果；执行的语句都会被记录
+type FakeDB struct {
+	mu      sync.Mutex
+	rules   []fakeRule
+	queries []Query
+	lastID  int64
+}
+
+// Query 记录的一条 SQL 及其参数
+type Query struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// Rows 查询返回的结果
+type Rows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+type fakeRule struct {
+	fragment string
+	arg      driver.Value // 不为空时还要求参数中包含该值
+	rows     *Rows
+}
+
+func (r fakeRule) matches(query string, args []driver.NamedValue) bool {
+	if !strings.Contains(query, r.fragment) {
+		return false
+	}
+	if r.arg == nil {
+		return true
+	}
+	for _, arg := range args {
+		if reflect.DeepEqual(arg.Value, r.arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFakeDB 创建假数据库，返回基于它的 gorm 连接
+func NewFakeDB(t *testing.T) (*FakeDB, *gorm.DB) {
+	t.Helper()
+	fake := &FakeDB{}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fake),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("open fake db: %v", err)
+	}
+	return fake, db
+}
+
+// On 设置 SQL 中包含 fragment 的查询返回的结果，先设置的规则优先匹配
+func (f *FakeDB) On(fragment string, rows *Rows) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{fragment: fragment, rows: rows})
+}
+
+// OnArg 设置 SQL 中包含 fragment 且参数中包含 arg 的查询返回的结果，用于区分同一张表的不同记录
+func (f *FakeDB) OnArg(fragment string, arg driver.Value, rows *Rows) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{fragment: fragment, arg: arg, rows: rows})
+}
+
+// Executed 返回 SQL 中包含 fragment 的已执行语句
+func (f *FakeDB) Executed(fragment string) []Query {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []Query
+	for _, q := range f.queries {
+		if strings.Contains(q.SQL, fragment) {
+			matched = append(matched, q)
+		}
+	}
+	return matched
+}
+
+func (f *FakeDB) record(query string, args []driver.NamedValue) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.queries = append(f.queries, Query{SQL: query, Args: values})
+}
+
+func (f *FakeDB) query(query string, args []driver.NamedValue) *Rows {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(query, args)
+	for _, rule := range f.rules {
+		if rule.matches(query, args) {
+			return rule.rows
+		}
+	}
+	return &Rows{}
+}
+
+func (f *FakeDB) exec(query string, args []driver.NamedValue) driver.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(query, args)
+	f.lastID++
+	return fakeResult{lastID: f.lastID}
+}
+
+// Connect 实现 driver.Connector
+func (f *FakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: f}, nil
+}
+
+// Driver 实现 driver.Connector
+func (f *FakeDB) Driver() driver.Driver {
+	return fakeDriver{db: f}
+}
+
+type fakeDriver struct{ db *FakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct{ db *FakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{rows: c.db.query(query, args)}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.db.exec(query, args), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeResult struct{ lastID int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.lastID, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	rows *Rows
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return r.rows.Columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.Values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.Values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"context"
+)
+
+// Envelope 类型
+const (
+	EnvelopeChat          = "chat"           // 广播给聊天室成员
+	EnvelopeUser          = "user"           // 发送给指定用户的所有设备
+	EnvelopeMemberAdded   = "member_added"   // 成员加入聊天室，同步订阅关系
+	EnvelopeMemberRemoved = "member_removed" // 成员离开聊天室，同步订阅关系
+)
+
+// Envelope 跨节点分发的消息信封
+// 每个节点先投递给本地连接，再通过 Broker 发布，其他节点收到后只投递给本地连接
+type Envelope struct {
+	NodeID        string     `json:"node_id"` // 发布节点，用于忽略自己发布的消息
+	Kind          string     `json:"kind"`
+	ChatID        int64      `json:"chat_id,omitempty"`
+	UserID        int64      `json:"user_id,omitempty"`
+	ExcludeUserID int64      `json:"exclude_user_id,omitempty"` // 跳过该用户的所有设备
+	ExcludeConnID string     `json:"exclude_conn_id,omitempty"` // 只跳过发起的连接
+	ViewersOnly   bool       `json:"viewers_only,omitempty"`    // 只发给正在查看该聊天室的连接
+	Message       *WSMessage `json:"message,omitempty"`
+}
+
+// Broker 跨节点消息分发接口
+// 多个 API 实例部署在负载均衡之后时，通过 Broker 把消息分发到持有对应连接的节点
+type Broker interface {
+	// Publish 发布消息信封给其他节点
+	Publish(ctx context.Context, env *Envelope) error
+
+	// Subscribe 接收其他节点发布的消息信封，阻塞直到 ctx 结束
+	Subscribe(ctx context.Context, handler func(env *Envelope)) error
+
+	// SetOnline 更新用户在指定节点上的在线状态
+	SetOnline(ctx context.Context, nodeID string, userID int64, online bool) error
+
+	// IsOnline 检查用户是否在任意节点上在线
+	IsOnline(ctx context.Context, userID int64) (bool, error)
+
+	// Close 释放资源
+	Close() error
+}
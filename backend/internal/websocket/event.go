@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/service"
 )
 
 // MessageEventHandler 消息事件处理器接口
@@ -13,3 +14,9 @@ type MessageEventHandler interface {
 
 // Hub 实现了 MessageEventHandler 接口
 var _ MessageEventHandler = (*Hub)(nil)
+
+// Hub 实现了 service.MembershipListener 接口，成员变更时同步订阅关系
+var _ service.MembershipListener = (*Hub)(nil)
+
+// Hub 实现了 service.EventBroadcaster 接口，推送消息编辑等实时事件
+var _ service.EventBroadcaster = (*Hub)(nil)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -24,70 +25,147 @@ var upgrader = websocket.Upgrader{
 }
 
 // MessageSaveHandler 消息保存处理函数类型
-type MessageSaveHandler func(ctx context.Context, msg *WSMessage) (*model.Message, error)
+// created 为 false 表示客户端重复提交，返回的是已保存的消息
+type MessageSaveHandler func(ctx context.Context, msg *WSMessage) (message *model.Message, created bool, err error)
 
 // OnlineChecker 用户在线检查函数类型
 type OnlineChecker func(userID int64) bool
 
+// ChatIDsLoader 加载用户所在聊天室ID的函数类型
+type ChatIDsLoader func(ctx context.Context, userID int64) ([]int64, error)
+
+// MessageReplayer 加载 lastSeqID 之后消息的函数类型，用于断线重连后补发
+type MessageReplayer func(ctx context.Context, userID, lastSeqID int64) (*service.SyncResponse, error)
+
+// DeliveryAcker 记录设备已收到消息的函数类型，返回首次送达的消息
+type DeliveryAcker func(ctx context.Context, userID int64, messageIDs []int64) ([]*model.Message, error)
+
+// ReadAcker 标记消息已读的函数类型，返回服务端确认已读的消息
+type ReadAcker func(ctx context.Context, userID, chatID int64, messageIDs []int64) ([]*model.Message, error)
+
+// maxPendingFrames 补发期间最多缓存的实时消息数，超出后断开连接由客户端重新恢复
+const maxPendingFrames = 1024
+
 // Hub WebSocket 消息中心
 type Hub struct {
-	clients         map[int64]*Client
+	clients        map[int64]map[string]*Client // userID -> connID -> client，同一用户可同时在多个设备上连接
 	register       chan *Client
 	unregister     chan *Client
 	broadcast      chan *WSMessage
 	messageHandler MessageEventHandler    // 消息事件处理器，用于保存消息到数据库
 	messageSaver   MessageSaveHandler     // 消息保存回调，用于将 WebSocket 消息保存到数据库
 	onlineChecker  OnlineChecker         // 用户在线检查回调
-	chatMembers    map[int64]map[int64]bool // chatID -> map[userID] -> joined
+	chatIDsLoader  ChatIDsLoader         // 连接建立时加载用户所在的聊天室
+	replayer       MessageReplayer       // 断线重连后补发消息
+	deliveryAcker  DeliveryAcker         // 记录消息送达
+	readAcker      ReadAcker             // 记录消息已读
+	chatMembers    map[int64]map[int64]bool // chatID -> map[userID] -> joined，仅包含在线用户
+	userChats      map[int64]map[int64]bool // userID -> map[chatID] -> joined，chatMembers 的反向索引
 	chatMembersMu  sync.RWMutex
 	mu             sync.RWMutex
+	broker         Broker // 跨节点分发，为空时只投递给本节点的连接
+	nodeID         string // 当前节点标识
 }
 
 // Client WebSocket 客户端连接
+// 每个 WebSocket 连接对应一个 Client，同一用户的多个设备各自拥有独立的 Client
 type Client struct {
-	hub    *Hub
-	conn   *websocket.Conn
-	send   chan []byte
-	userID int64
-	chatID int64 // 当前所在的聊天室
+	hub      *Hub
+	conn     *websocket.Conn
+	send     chan []byte
+	userID   int64
+	deviceID string // 客户端上报的设备标识（可为空）
+	connID   string // 连接唯一标识，用于区分同一用户的多个连接
+	chatID   int64  // 当前正在查看的聊天室，仅用于正在输入和已读状态
+	chatIDs  []int64 // 连接建立时加载的聊天室，注册时订阅
+	done     chan struct{} // 注销时关闭，通知 writePump 和补发协程退出
+
+	// 补发期间实时消息先缓存在 pending 中，补发完成后按序发送
+	resumeMu sync.Mutex
+	resuming bool
+	pending  []pendingFrame
+}
+
+// pendingFrame 补发期间缓存的实时消息
+type pendingFrame struct {
+	seqID int64 // 聊天消息的序列号，用于与补发的消息去重；其他帧为 0
+	data  []byte
 }
 
 // WSMessage WebSocket 消息结构
 // 消息类型 (Type):
 //   - "message": 普通消息
-//   - "join_chat": 加入聊天室
-//   - "leave_chat": 离开聊天室
-//   - "WS_MSG_READ": 消息已读回执
+//   - "join_chat": 标记当前正在查看的聊天室（连接会自动订阅用户所有聊天室）
+//   - "leave_chat": 取消正在查看的聊天室
+//   - "WS_MSG_READ": 消息已读回执，客户端用 ChatID 和 MessageID 或 MessageIDs 标记已读，
+//     服务端确认后通知发送者，SenderID 为读取消息的用户
+//   - "WS_MSG_DELIVERED": 消息送达回执，客户端用 MessageID 或 MessageIDs 确认收到的消息，
+//     服务端记录首次送达后通知发送者，SenderID 为收到消息的用户
 //   - "WS_TYPING": 正在输入状态
+//   - "WS_RESUMED": 断线重连补发完成，SeqID 为补发到的最新序列号
+//   - "ack": 客户端请求处理成功的回复，聊天消息会带上 MessageID 和 SeqID
+//   - "error": 客户端请求处理失败的回复，Code 与 REST 接口的错误码一致
+//   - "message_edited": 消息被编辑，Data 为编辑后的消息
+//   - "message_deleted": 消息被删除，Data 为 {message_id, chat_id, for_me}
+//   - "reaction_updated": 消息的表情回应变化，Data 为 {message_id, chat_id, user_id, emoji, reactions}
+//   - "message_pinned": 消息被置顶或取消置顶，Data 为 {chat_id, message_id, pinned, user_id}
+//   - "poll_updated": 投票结果变化或投票结束，Data 为 {chat_id, message_id, poll}
+//   - "member_joined": 新成员加入群组，Data 为 {chat_id, user_id, inviter_id}
+//   - "join_request": 自己的加入申请被处理，Data 为 {chat_id, approved}
+//   - "member_left": 成员退出聊天室，Data 为 {chat_id, user_id, new_owner_id}
+//   - "chat_updated": 聊天室名称、头像或简介被修改，Data 为修改后的聊天室
+//   - "chat_deleted": 聊天室被删除，Data 为 {chat_id}
+//
+// 客户端发送的每一帧都会收到一个 ack 或 error 回复，ReqID 原样带回用于关联请求
 type WSMessage struct {
-	Type       string          `json:"type"`
-	SeqID      int64           `json:"seq_id,omitempty"`      // 消息序列号，用于前端去重
-	MessageID  int64           `json:"message_id,omitempty"`  // 数据库消息ID
-	ChatID     int64           `json:"chat_id,omitempty"`
-	SenderID   int64           `json:"sender_id,omitempty"`
-	Content    string          `json:"content,omitempty"`
-	MediaURL   string          `json:"media_url,omitempty"`
-	MsgType    int             `json:"msg_type,omitempty"`    // 消息类型：1:text, 2:image, 3:file, 4:voice, 5:location
-	Timestamp  time.Time       `json:"timestamp"`
-	Data       json.RawMessage `json:"data,omitempty"`
-	MessageIDs []int64         `json:"message_ids,omitempty"` // 用于已读确认
+	Type        string              `json:"type"`
+	ReqID       string              `json:"req_id,omitempty"`     // 客户端生成的请求ID，回复时原样返回
+	Code        int                 `json:"code,omitempty"`       // 错误码，仅 error 回复
+	Error       string              `json:"error,omitempty"`      // 错误信息，仅 error 回复
+	SeqID       int64               `json:"seq_id,omitempty"`     // 消息序列号，用于前端去重
+	MessageID   int64               `json:"message_id,omitempty"` // 数据库消息ID
+	ChatID      int64               `json:"chat_id,omitempty"`
+	SenderID    int64               `json:"sender_id,omitempty"`
+	Content     string              `json:"content,omitempty"`
+	MediaURL    string              `json:"media_url,omitempty"`
+	MsgType     int                 `json:"msg_type,omitempty"` // 消息类型：1:text, 2:image, 3:file, 4:voice, 5:location
+	Timestamp   time.Time           `json:"timestamp"`
+	Data        json.RawMessage     `json:"data,omitempty"`
+	MessageIDs  []int64             `json:"message_ids,omitempty"`   // 用于已读和送达确认
+	ClientMsgID string              `json:"client_msg_id,omitempty"` // 客户端生成的随机ID，重发时用于去重
+	ReplyID     int64               `json:"reply_id,omitempty"`      // 回复的消息ID
+	ReplyTo     *model.MessageQuote `json:"reply_to,omitempty"`      // 被回复消息的摘要，由服务端填充
+	TTL         int                 `json:"ttl,omitempty"`           // 阅后即焚秒数，从首次被读开始计时
+	Poll        *model.Poll         `json:"poll,omitempty"`          // 投票消息的投票，发送时只需填写与 REST 接口相同的字段，结果由服务端填充
+	Signature   string              `json:"signature,omitempty"`     // 频道消息的发布者签名，由服务端填充
+	Action      string              `json:"action,omitempty"`        // 服务消息的操作类型，由服务端填充
+
+	// 转发消息的来源
+	ForwardedFromChatID    int64 `json:"forwarded_from_chat_id,omitempty"`
+	ForwardedFromMessageID int64 `json:"forwarded_from_message_id,omitempty"`
+	ForwardedFromSenderID  int64 `json:"forwarded_from_sender_id,omitempty"`
 }
 
 // WSMessageType constants
 const (
 	WSMessageType         = "message"
 	WSMsgReadType         = "WS_MSG_READ"
+	WSMsgDeliveredType    = "WS_MSG_DELIVERED"
 	WSTypingType          = "WS_TYPING"
+	WSResumedType         = "WS_RESUMED"
+	WSAckType             = "ack"
+	WSErrorType           = "error"
 )
 
 // NewHub 创建新的 Hub 实例
 func NewHub() *Hub {
 	return &Hub{
-		clients:      make(map[int64]*Client),
+		clients:      make(map[int64]map[string]*Client),
 		register:     make(chan *Client, 10),
 		unregister:   make(chan *Client, 10),
 		broadcast:    make(chan *WSMessage, 256),
 		chatMembers:  make(map[int64]map[int64]bool),
+		userChats:    make(map[int64]map[int64]bool),
 	}
 }
 
@@ -108,17 +186,63 @@ func (h *Hub) SetOnlineChecker(checker OnlineChecker) {
 	h.onlineChecker = checker
 }
 
+// SetBroker 设置跨节点分发
+// nodeID 为空时自动生成；同一节点重启后使用新的标识，避免沿用旧的在线状态
+func (h *Hub) SetBroker(broker Broker, nodeID string) {
+	if nodeID == "" {
+		nodeID = "node"
+	}
+	h.broker = broker
+	h.nodeID = nodeID + "-" + service.RandomString(6)
+}
+
+// SetMessageReplayer 设置消息补发回调
+// 客户端携带 last_seq_id 重连时，用于补发断线期间错过的消息
+func (h *Hub) SetMessageReplayer(replayer MessageReplayer) {
+	h.replayer = replayer
+}
+
+// SetChatIDsLoader 设置聊天室加载回调
+// 用于在连接建立时订阅用户所在的全部聊天室
+func (h *Hub) SetChatIDsLoader(loader ChatIDsLoader) {
+	h.chatIDsLoader = loader
+}
+
+// SetDeliveryAcker 设置消息送达回调
+// 客户端发送 WS_MSG_DELIVERED 时用于记录送达状态
+func (h *Hub) SetDeliveryAcker(acker DeliveryAcker) {
+	h.deliveryAcker = acker
+}
+
+// SetReadAcker 设置消息已读回调
+// 客户端发送 WS_MSG_READ 时用于校验并记录已读状态，与 REST 接口一致
+func (h *Hub) SetReadAcker(acker ReadAcker) {
+	h.readAcker = acker
+}
+
 // IsUserOnline 检查用户是否有 WebSocket 连接
 func (h *Hub) IsUserOnline(userID int64) bool {
 	// 首先检查本地连接
 	h.mu.RLock()
-	_, hasLocalConn := h.clients[userID]
+	hasLocalConn := len(h.clients[userID]) > 0
 	h.mu.RUnlock()
 
 	if hasLocalConn {
 		return true
 	}
 
+	// 检查其他节点上的连接
+	if h.broker != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		online, err := h.broker.IsOnline(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to check presence for user %d: %v", userID, err)
+		} else if online {
+			return true
+		}
+	}
+
 	// 如果设置了外部在线检查回调，也检查
 	if h.onlineChecker != nil {
 		return h.onlineChecker(userID)
@@ -129,30 +253,56 @@ func (h *Hub) IsUserOnline(userID int64) bool {
 
 // Run 启动 Hub
 func (h *Hub) Run() {
+	if h.broker != nil {
+		go func() {
+			if err := h.broker.Subscribe(context.Background(), h.onRemote); err != nil {
+				log.Printf("Broker subscription stopped: %v", err)
+			}
+		}()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
-			h.clients[client.userID] = client
-			// 将用户加入聊天室
-			if client.chatID > 0 {
-				h.addUserToChat(client.chatID, client.userID)
+			conns := h.clients[client.userID]
+			if conns == nil {
+				conns = make(map[string]*Client)
+				h.clients[client.userID] = conns
+				h.setOnline(client.userID, true)
+			}
+			// 同一设备重复连接时，旧连接视为已失效，关闭后由其 readPump 自行注销
+			if client.deviceID != "" {
+				for _, old := range conns {
+					if old.deviceID == client.deviceID {
+						old.conn.Close()
+					}
+				}
+			}
+			conns[client.connID] = client
+			// 订阅用户所在的全部聊天室
+			for _, chatID := range client.chatIDs {
+				h.addUserToChat(chatID, client.userID)
 			}
 			h.mu.Unlock()
-			log.Printf("User %d connected, total clients: %d", client.userID, len(h.clients))
+			log.Printf("User %d connected (conn %s), devices: %d", client.userID, client.connID, len(conns))
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.userID]; ok {
-				// 将用户从聊天室移除
-				if client.chatID > 0 {
-					h.removeUserFromChat(client.chatID, client.userID)
+			conns := h.clients[client.userID]
+			// 只注销真正关闭的那个连接，同一用户的其他设备不受影响
+			if conns[client.connID] == client {
+				delete(conns, client.connID)
+				// 最后一个连接断开时取消该用户的全部订阅
+				if len(conns) == 0 {
+					delete(h.clients, client.userID)
+					h.removeUserFromAllChats(client.userID)
+					h.setOnline(client.userID, false)
 				}
-				delete(h.clients, client.userID)
-				close(client.send)
+				close(client.done)
 			}
 			h.mu.Unlock()
-			log.Printf("User %d disconnected, total clients: %d", client.userID, len(h.clients))
+			log.Printf("User %d disconnected (conn %s), devices: %d", client.userID, client.connID, len(conns))
 
 		case message := <-h.broadcast:
 			h.broadcastToChat(message)
@@ -160,6 +310,60 @@ func (h *Hub) Run() {
 	}
 }
 
+// setOnline 同步用户在本节点的在线状态到 Broker
+func (h *Hub) setOnline(userID int64, online bool) {
+	if h.broker == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.broker.SetOnline(ctx, h.nodeID, userID, online); err != nil {
+		log.Printf("Failed to update presence for user %d: %v", userID, err)
+	}
+}
+
+// dispatch 投递给本节点的连接，并发布给其他节点
+func (h *Hub) dispatch(env *Envelope) {
+	h.deliver(env)
+
+	if h.broker == nil {
+		return
+	}
+	env.NodeID = h.nodeID
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.broker.Publish(ctx, env); err != nil {
+		log.Printf("Failed to publish %s envelope: %v", env.Kind, err)
+	}
+}
+
+// onRemote 处理其他节点发布的消息信封
+func (h *Hub) onRemote(env *Envelope) {
+	if env.NodeID == h.nodeID {
+		return
+	}
+	h.deliver(env)
+}
+
+// deliver 把消息信封投递给本节点的连接
+func (h *Hub) deliver(env *Envelope) {
+	switch env.Kind {
+	case EnvelopeChat:
+		h.broadcastToChatExcept(env.Message, func(client *Client) bool {
+			return (env.ExcludeUserID != 0 && client.userID == env.ExcludeUserID) ||
+				(env.ExcludeConnID != "" && client.connID == env.ExcludeConnID) ||
+				(env.ViewersOnly && client.chatID != env.ChatID)
+		})
+	case EnvelopeUser:
+		h.sendToLocalUser(env.UserID, env.Message)
+	case EnvelopeMemberAdded:
+		h.subscribe(env.ChatID, env.UserID)
+	case EnvelopeMemberRemoved:
+		h.unsubscribe(env.ChatID, env.UserID)
+	}
+}
+
 func (h *Hub) addUserToChat(chatID, userID int64) {
 	h.chatMembersMu.Lock()
 	defer h.chatMembersMu.Unlock()
@@ -168,20 +372,104 @@ func (h *Hub) addUserToChat(chatID, userID int64) {
 		h.chatMembers[chatID] = make(map[int64]bool)
 	}
 	h.chatMembers[chatID][userID] = true
+
+	if h.userChats[userID] == nil {
+		h.userChats[userID] = make(map[int64]bool)
+	}
+	h.userChats[userID][chatID] = true
 }
 
 func (h *Hub) removeUserFromChat(chatID, userID int64) {
 	h.chatMembersMu.Lock()
 	defer h.chatMembersMu.Unlock()
 
-	if h.chatMembers[chatID] != nil {
-		delete(h.chatMembers[chatID], userID)
+	h.unindex(chatID, userID)
+}
+
+func (h *Hub) removeUserFromAllChats(userID int64) {
+	h.chatMembersMu.Lock()
+	defer h.chatMembersMu.Unlock()
+
+	for chatID := range h.userChats[userID] {
+		h.unindex(chatID, userID)
+	}
+}
+
+// unindex 从订阅索引中移除，调用方需持有 chatMembersMu
+func (h *Hub) unindex(chatID, userID int64) {
+	if members := h.chatMembers[chatID]; members != nil {
+		delete(members, userID)
+		if len(members) == 0 {
+			delete(h.chatMembers, chatID)
+		}
+	}
+	if chats := h.userChats[userID]; chats != nil {
+		delete(chats, chatID)
+		if len(chats) == 0 {
+			delete(h.userChats, userID)
+		}
+	}
+}
+
+// isSubscribed 检查用户是否订阅了指定聊天室（即是否为该聊天室成员）
+func (h *Hub) isSubscribed(userID, chatID int64) bool {
+	h.chatMembersMu.RLock()
+	defer h.chatMembersMu.RUnlock()
+	return h.chatMembers[chatID][userID]
+}
+
+// OnMemberAdded 实现 service.MembershipListener 接口
+// 成员在线时立即订阅新聊天室（包括其他节点上的连接）
+func (h *Hub) OnMemberAdded(chatID, userID int64) {
+	h.dispatch(&Envelope{Kind: EnvelopeMemberAdded, ChatID: chatID, UserID: userID})
+}
+
+// OnMemberRemoved 实现 service.MembershipListener 接口
+func (h *Hub) OnMemberRemoved(chatID, userID int64) {
+	h.dispatch(&Envelope{Kind: EnvelopeMemberRemoved, ChatID: chatID, UserID: userID})
+}
+
+// subscribe 用户在本节点在线时订阅聊天室
+func (h *Hub) subscribe(chatID, userID int64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.clients[userID]) > 0 {
+		h.addUserToChat(chatID, userID)
+	}
+}
+
+// unsubscribe 取消订阅并清除该聊天室的查看状态
+func (h *Hub) unsubscribe(chatID, userID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeUserFromChat(chatID, userID)
+	for _, client := range h.clients[userID] {
+		if client.chatID == chatID {
+			client.chatID = 0
+		}
 	}
 }
 
 func (h *Hub) broadcastToChat(msg *WSMessage) {
+	h.dispatch(&Envelope{Kind: EnvelopeChat, ChatID: msg.ChatID, Message: msg})
+}
+
+// broadcastToChatExcludeConn 广播消息给聊天室成员，仅排除发起的连接
+// 发送者的其他设备仍会收到，用于多设备间同步状态
+func (h *Hub) broadcastToChatExcludeConn(msg *WSMessage, exclude *Client) {
+	h.dispatch(&Envelope{Kind: EnvelopeChat, ChatID: msg.ChatID, ExcludeConnID: exclude.connID, Message: msg})
+}
+
+// broadcastToChatExcept 广播消息给本节点上聊天室成员的所有连接，skip 返回 true 的连接会被跳过
+func (h *Hub) broadcastToChatExcept(msg *WSMessage, skip func(client *Client) bool) {
 	h.chatMembersMu.RLock()
 	members, ok := h.chatMembers[msg.ChatID]
+	userIDs := make([]int64, 0, len(members))
+	for userID := range members {
+		userIDs = append(userIDs, userID)
+	}
 	h.chatMembersMu.RUnlock()
 
 	if !ok {
@@ -192,47 +480,125 @@ func (h *Hub) broadcastToChat(msg *WSMessage) {
 	defer h.mu.RUnlock()
 
 	data := h.encodeMessage(msg)
-	for userID := range members {
-		if client, exists := h.clients[userID]; exists {
-			select {
-			case client.send <- data:
-			default:
-				// 发送缓冲区已满，记录警告日志并丢弃消息
-				// 不关闭连接，允许客户端重连或等待
-				log.Printf("Warning: send buffer full for user %d, message dropped", userID)
+	for _, userID := range userIDs {
+		for _, client := range h.clients[userID] {
+			if skip != nil && skip(client) {
+				continue
 			}
+			client.trySend(data, resumeSeqID(msg))
 		}
 	}
 }
 
-// broadcastToChatExcludeSender 广播消息给聊天室成员，排除发送者
-func (h *Hub) broadcastToChatExcludeSender(msg *WSMessage, excludeUserID int64) {
-	h.chatMembersMu.RLock()
-	members, ok := h.chatMembers[msg.ChatID]
-	h.chatMembersMu.RUnlock()
+// resumeSeqID 返回用于补发去重的序列号，只有聊天消息参与去重
+func resumeSeqID(msg *WSMessage) int64 {
+	if msg.Type == WSMessageType {
+		return msg.SeqID
+	}
+	return 0
+}
 
-	if !ok {
+// trySend 非阻塞地写入发送缓冲区
+// 补发期间先缓存；缓冲区已满时断开连接，客户端携带 last_seq_id 重连后补发，避免静默丢消息
+func (c *Client) trySend(data []byte, seqID int64) {
+	c.resumeMu.Lock()
+	if c.resuming {
+		if len(c.pending) < maxPendingFrames {
+			c.pending = append(c.pending, pendingFrame{seqID: seqID, data: data})
+			c.resumeMu.Unlock()
+			return
+		}
+		c.resumeMu.Unlock()
+		log.Printf("Warning: too many pending frames for user %d (conn %s), closing connection", c.userID, c.connID)
+		c.conn.Close()
 		return
 	}
+	c.resumeMu.Unlock()
 
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	select {
+	case c.send <- data:
+	default:
+		log.Printf("Warning: send buffer full for user %d (conn %s), closing connection", c.userID, c.connID)
+		c.conn.Close()
+	}
+}
 
-	data := h.encodeMessage(msg)
-	for userID := range members {
-		if userID == excludeUserID {
-			continue
+// sendBlocking 阻塞写入发送缓冲区，连接注销后返回 false
+func (c *Client) sendBlocking(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// replay 补发 lastSeqID 之后的消息，完成后切换为实时投递
+// 补发失败时不发送 WS_RESUMED，回复错误后断开连接，由客户端携带原 last_seq_id 重连重试
+func (c *Client) replay(lastSeqID int64) {
+	seqID := lastSeqID
+	for {
+		resp, err := c.hub.replayer(context.Background(), c.userID, seqID)
+		if err != nil {
+			log.Printf("Failed to replay messages for user %d: %v", c.userID, err)
+			c.closeWithError(500, "failed to replay messages")
+			return
+		}
+
+		for _, message := range resp.Messages {
+			if !c.sendBlocking(c.hub.encodeMessage(newMessageFrame(message))) {
+				return
+			}
+		}
+		for _, message := range resp.Edits {
+			if !c.sendBlocking(c.hub.encodeMessage(newEventFrame(message.ChatID, service.EventMessageEdited, message))) {
+				return
+			}
+		}
+		for _, tombstone := range resp.Deleted {
+			if !c.sendBlocking(c.hub.encodeMessage(newEventFrame(tombstone.ChatID, service.EventMessageDeleted, tombstone))) {
+				return
+			}
+		}
+		if resp.SeqID > seqID {
+			seqID = resp.SeqID
+		}
+
+		if !resp.HasMore {
+			break
+		}
+	}
+	c.flushPending(seqID)
+}
+
+// flushPending 发送补发期间缓存的实时消息，跳过已补发过的聊天消息
+func (c *Client) flushPending(replayedSeqID int64) {
+	for {
+		c.resumeMu.Lock()
+		frames := c.pending
+		c.pending = nil
+		if len(frames) == 0 {
+			c.resuming = false
+			c.resumeMu.Unlock()
+			break
 		}
-		if client, exists := h.clients[userID]; exists {
-			select {
-			case client.send <- data:
-			default:
-				// 发送缓冲区已满，记录警告日志并丢弃消息
-				// 不关闭连接，允许客户端重连或等待
-				log.Printf("Warning: send buffer full for user %d, message dropped", userID)
+		c.resumeMu.Unlock()
+
+		for _, frame := range frames {
+			if frame.seqID > 0 && frame.seqID <= replayedSeqID {
+				continue
+			}
+			if !c.sendBlocking(frame.data) {
+				return
 			}
 		}
 	}
+
+	c.sendBlocking(c.hub.encodeMessage(&WSMessage{
+		Type:      WSResumedType,
+		SeqID:     replayedSeqID,
+		Timestamp: time.Now(),
+	}))
 }
 
 func (h *Hub) encodeMessage(msg *WSMessage) []byte {
@@ -240,31 +606,68 @@ func (h *Hub) encodeMessage(msg *WSMessage) []byte {
 	return data
 }
 
-// GetClient 获取用户客户端连接
-func (h *Hub) GetClient(userID int64) *Client {
+// GetClients 获取用户的所有客户端连接
+func (h *Hub) GetClients(userID int64) []*Client {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.clients[userID]
+
+	clients := make([]*Client, 0, len(h.clients[userID]))
+	for _, client := range h.clients[userID] {
+		clients = append(clients, client)
+	}
+	return clients
 }
 
-// SendToUser 发送消息给指定用户
+// SendToUser 发送消息给指定用户的所有设备（包括其他节点上的连接）
 func (h *Hub) SendToUser(userID int64, msg *WSMessage) {
+	h.dispatch(&Envelope{Kind: EnvelopeUser, UserID: userID, Message: msg})
+}
+
+// SendReceipt 通知消息发送者消息已送达或已读
+// receiptType 为 WS_MSG_DELIVERED 或 WS_MSG_READ，userID 为收到或读取消息的用户
+func (h *Hub) SendReceipt(receiptType string, message *model.Message, userID int64, at time.Time) {
+	h.SendToUser(message.SenderID, &WSMessage{
+		Type:      receiptType,
+		MessageID: message.ID,
+		SeqID:     message.SeqID,
+		ChatID:    message.ChatID,
+		SenderID:  userID,
+		Timestamp: at,
+	})
+}
+
+// sendToLocalUser 发送消息给指定用户在本节点上的所有设备
+func (h *Hub) sendToLocalUser(userID int64, msg *WSMessage) {
 	h.mu.RLock()
-	client, ok := h.clients[userID]
-	h.mu.RUnlock()
-	if ok {
-		select {
-		case client.send <- h.encodeMessage(msg):
-		default:
-		}
+	defer h.mu.RUnlock()
+
+	conns := h.clients[userID]
+	if len(conns) == 0 {
+		return
+	}
+
+	data := h.encodeMessage(msg)
+	for _, client := range conns {
+		client.trySend(data, resumeSeqID(msg))
 	}
 }
 
 // OnMessageSaved 实现 MessageEventHandler 接口
 // 消息保存成功后调用此方法进行广播
 func (h *Hub) OnMessageSaved(message *model.Message) {
-	wsMsg := &WSMessage{
-		Type:      "message",
+	wsMsg := newMessageFrame(message)
+
+	select {
+	case h.broadcast <- wsMsg:
+	default:
+		log.Println("broadcast channel is full, message may be dropped")
+	}
+}
+
+// newMessageFrame 把数据库消息转换为 WebSocket 消息
+func newMessageFrame(message *model.Message) *WSMessage {
+	return &WSMessage{
+		Type:      WSMessageType,
 		SeqID:     message.SeqID,
 		MessageID: message.ID,
 		ChatID:    message.ChatID,
@@ -273,44 +676,104 @@ func (h *Hub) OnMessageSaved(message *model.Message) {
 		MediaURL:  message.MediaURL,
 		MsgType:   message.Type,
 		Timestamp: message.CreatedAt,
+		ReplyID:   message.ReplyID,
+		ReplyTo:   message.ReplyTo,
+		TTL:       message.TTL,
+		Poll:      message.Poll,
+		Signature: message.Signature,
+		Action:    message.Action,
+
+		ForwardedFromChatID:    message.ForwardedFromChatID,
+		ForwardedFromMessageID: message.ForwardedFromMessageID,
+		ForwardedFromSenderID:  message.ForwardedFromSenderID,
 	}
+}
 
-	select {
-	case h.broadcast <- wsMsg:
-	default:
-		log.Println("broadcast channel is full, message may be dropped")
+// PollRequest 客户端发送投票消息时填写的投票内容，未填写投票时返回空
+func (m *WSMessage) PollRequest() *service.PollRequest {
+	if m.Poll == nil {
+		return nil
+	}
+	req := &service.PollRequest{
+		Question:       m.Poll.Question,
+		Options:        m.Poll.Options,
+		PublicVoters:   m.Poll.PublicVoters,
+		MultipleChoice: m.Poll.MultipleChoice,
+		IsQuiz:         m.Poll.IsQuiz,
+		CloseAt:        m.Poll.CloseAt,
+	}
+	// 测验的正确选项与 REST 接口一样通过 correct_option 提交
+	if m.Poll.Correct != nil {
+		req.CorrectOption = *m.Poll.Correct
 	}
+	return req
 }
 
-// JoinChat 用户加入聊天室
-func (h *Hub) JoinChat(userID, chatID int64) {
-	h.mu.RLock()
-	client, ok := h.clients[userID]
-	h.mu.RUnlock()
+// OnChatEvent 实现 service.EventBroadcaster 接口，推送事件给聊天室成员的所有连接
+func (h *Hub) OnChatEvent(chatID int64, eventType string, payload interface{}) {
+	h.broadcastToChat(newEventFrame(chatID, eventType, payload))
+}
 
-	if ok {
-		// 从旧聊天室移除
-		if client.chatID > 0 {
-			h.removeUserFromChat(client.chatID, userID)
-		}
-		// 加入新聊天室
-		client.chatID = chatID
-		h.addUserToChat(chatID, userID)
+// OnUserEvent 实现 service.EventBroadcaster 接口，推送事件给用户的所有设备
+func (h *Hub) OnUserEvent(userID int64, eventType string, payload interface{}) {
+	h.SendToUser(userID, newEventFrame(0, eventType, payload))
+}
+
+// newEventFrame 构造事件帧，payload 编码后放在 Data 中
+func newEventFrame(chatID int64, eventType string, payload interface{}) *WSMessage {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", eventType, err)
+	}
+	return &WSMessage{
+		Type:      eventType,
+		ChatID:    chatID,
+		Timestamp: time.Now(),
+		Data:      data,
 	}
 }
 
-// LeaveChat 用户离开聊天室
-func (h *Hub) LeaveChat(userID, chatID int64) {
-	h.mu.RLock()
-	client, ok := h.clients[userID]
-	h.mu.RUnlock()
+// JoinChat 标记连接当前正在查看的聊天室，不是该聊天室成员时返回 false
+// 消息投递不依赖于此，连接会收到用户所有聊天室的消息；这里只影响正在输入和已读状态
+func (h *Hub) JoinChat(client *Client, chatID int64) bool {
+	if !h.isSubscribed(client.userID, chatID) {
+		return false
+	}
 
-	if ok && client.chatID == chatID {
-		h.removeUserFromChat(chatID, userID)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.chatID = chatID
+	return true
+}
+
+// LeaveChat 清除连接正在查看的聊天室
+func (h *Hub) LeaveChat(client *Client, chatID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.chatID == chatID {
 		client.chatID = 0
 	}
 }
 
+// viewingChat 返回连接当前正在查看的聊天室
+func (h *Hub) viewingChat(client *Client) int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return client.chatID
+}
+
+// broadcastTyping 把正在输入状态发给正在查看该聊天室的其他成员
+func (h *Hub) broadcastTyping(msg *WSMessage, sender *Client) {
+	h.dispatch(&Envelope{
+		Kind:          EnvelopeChat,
+		ChatID:        msg.ChatID,
+		ExcludeUserID: sender.userID,
+		ViewersOnly:   true,
+		Message:       msg,
+	})
+}
+
 // ServeWS WebSocket 处理函数
 func ServeWS(hub *Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -330,23 +793,55 @@ func ServeWS(hub *Hub) gin.HandlerFunc {
 
 		userID := claims.UserID
 
+		// 加载用户所在的全部聊天室，注册后即可收到每个聊天室的新消息
+		// 加载失败时拒绝连接，避免客户端在线却收不到任何聊天室的消息
+		var chatIDs []int64
+		if hub.chatIDsLoader != nil {
+			var err error
+			chatIDs, err = hub.chatIDsLoader(c.Request.Context(), userID)
+			if err != nil {
+				log.Printf("Failed to load chats for user %d: %v", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chats"})
+				return
+			}
+		}
+
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			log.Println("upgrade error:", err)
 			return
 		}
 
+		// 同一用户可在多个设备上同时连接，device_id 用于识别同一设备的重连
+		deviceID := c.Query("device_id")
+		connID := service.RandomString(12)
+		if deviceID != "" {
+			connID = deviceID + ":" + connID
+		}
+
+		// 携带 last_seq_id 重连时，先补发断线期间错过的消息再切换为实时投递
+		lastSeqID, err := strconv.ParseInt(c.Query("last_seq_id"), 10, 64)
+		resume := err == nil && hub.replayer != nil
+
 		client := &Client{
-			hub:    hub,
-			conn:   conn,
-			send:   make(chan []byte, 1024),
-			userID: userID,
+			hub:      hub,
+			conn:     conn,
+			send:     make(chan []byte, 1024),
+			userID:   userID,
+			deviceID: deviceID,
+			connID:   connID,
+			chatIDs:  chatIDs,
+			done:     make(chan struct{}),
+			resuming: resume,
 		}
 
 		hub.register <- client
 
 		go client.writePump()
 		go client.readPump()
+		if resume {
+			go client.replay(lastSeqID)
+		}
 	}
 }
 
@@ -368,64 +863,173 @@ func (c *Client) readPump() {
 		var wsMsg WSMessage
 		if err := json.Unmarshal(message, &wsMsg); err != nil {
 			log.Printf("Failed to unmarshal message: %v", err)
+			c.replyError("", 400, "Invalid message format")
 			continue
 		}
 
 		wsMsg.SenderID = c.userID
 		wsMsg.Timestamp = time.Now()
 
-		// 处理加入聊天室消息
+		// 处理查看聊天室消息
 		if wsMsg.Type == "join_chat" {
-			c.hub.JoinChat(c.userID, wsMsg.ChatID)
+			if !c.hub.JoinChat(c, wsMsg.ChatID) {
+				c.replyErr(wsMsg.ReqID, service.ErrNotAuthorized)
+				continue
+			}
+			c.replyAck(wsMsg.ReqID, nil)
 			continue
 		}
 
-		// 处理离开聊天室消息
+		// 处理离开查看的聊天室消息
 		if wsMsg.Type == "leave_chat" {
-			c.hub.LeaveChat(c.userID, wsMsg.ChatID)
+			c.hub.LeaveChat(c, wsMsg.ChatID)
+			c.replyAck(wsMsg.ReqID, nil)
 			continue
 		}
 
 		// 处理聊天消息
 		if wsMsg.Type == WSMessageType {
+			frame := &wsMsg
 			// 如果有消息保存回调，先保存到数据库
 			if c.hub.messageSaver != nil {
-				dbMsg, err := c.hub.messageSaver(context.Background(), &wsMsg)
+				dbMsg, created, err := c.hub.messageSaver(context.Background(), &wsMsg)
 				if err != nil {
 					log.Printf("Failed to save message: %v", err)
-					// 可以选择不广播，或者发送错误消息给客户端
+					c.replyErr(wsMsg.ReqID, err)
+					continue
+				}
+				// 广播保存后的消息，与 REST 接口一致；转发来源、签名、投票等由服务端填充，不使用客户端提交的值
+				frame = newMessageFrame(dbMsg)
+				// 重复提交的消息已经广播过，只回复 ack
+				if !created {
+					c.replyAck(wsMsg.ReqID, frame)
+					continue
+				}
+			}
+			c.replyAck(wsMsg.ReqID, frame)
+			// 保存成功后广播消息，回复用的 ReqID 不需要广播
+			frame.ReqID = ""
+			c.hub.broadcast <- frame
+			continue
+		}
+
+		// 处理消息送达回执 (WS_MSG_DELIVERED)
+		// 由服务器记录送达状态，只通知首次送达的消息的发送者
+		if wsMsg.Type == WSMsgDeliveredType {
+			messageIDs := wsMsg.MessageIDs
+			if wsMsg.MessageID != 0 {
+				messageIDs = append(messageIDs, wsMsg.MessageID)
+			}
+			if c.hub.deliveryAcker != nil && len(messageIDs) > 0 {
+				delivered, err := c.hub.deliveryAcker(context.Background(), c.userID, messageIDs)
+				if err != nil {
+					log.Printf("Failed to ack delivery: %v", err)
+					c.replyErr(wsMsg.ReqID, err)
 					continue
 				}
-				// 更新消息的数据库 ID 和 SeqID
-				if dbMsg != nil {
-					wsMsg.MessageID = dbMsg.ID
-					wsMsg.SeqID = dbMsg.SeqID
+				for _, message := range delivered {
+					c.hub.SendReceipt(WSMsgDeliveredType, message, c.userID, *message.DeliveredAt)
 				}
 			}
-			// 保存成功后广播消息
-			c.hub.broadcast <- &wsMsg
+			c.replyAck(wsMsg.ReqID, nil)
+			continue
+		}
+
+		// 其余事件只由服务端产生，客户端只能发送正在输入和已读状态
+		if wsMsg.Type != WSTypingType && wsMsg.Type != WSMsgReadType {
+			c.replyErr(wsMsg.ReqID, service.ErrUnsupportedMessageType)
 			continue
 		}
 
+		// 正在输入和已读状态未指定聊天室时，使用当前正在查看的聊天室
+		if wsMsg.ChatID == 0 {
+			wsMsg.ChatID = c.hub.viewingChat(c)
+		}
+
+		// 以下状态只允许发往自己所在的聊天室
+		if !c.hub.isSubscribed(c.userID, wsMsg.ChatID) {
+			c.replyErr(wsMsg.ReqID, service.ErrNotAuthorized)
+			continue
+		}
+
+		// 回复用的 ReqID 不需要转发给其他成员
+		reqID := wsMsg.ReqID
+		wsMsg.ReqID = ""
+
 		// 处理正在输入状态 (WS_TYPING)
-		// 不存数据库，纯透传给聊天室其他成员
+		// 不存数据库，纯透传给正在查看该聊天室的其他成员
 		if wsMsg.Type == WSTypingType {
-			c.hub.broadcastToChatExcludeSender(&wsMsg, c.userID)
+			c.hub.broadcastTyping(&wsMsg, c)
+			c.replyAck(reqID, nil)
 			continue
 		}
 
 		// 处理消息已读回执 (WS_MSG_READ)
-		// 需要通过服务器确认并通知消息发送者
+		// 由服务器校验并记录已读状态，只把确认已读的消息通知给发送者，客户端发来的回执不直接转发
 		if wsMsg.Type == WSMsgReadType {
-			// TODO: 可以在这里调用 Service 更新已读状态
-			// 或者让客户端调用 REST API，然后通过服务器通知
-			// 这里直接广播给聊天室成员
-			c.hub.broadcastToChatExcludeSender(&wsMsg, c.userID)
-			continue
+			messageIDs := wsMsg.MessageIDs
+			if wsMsg.MessageID != 0 {
+				messageIDs = append(messageIDs, wsMsg.MessageID)
+			}
+			if c.hub.readAcker != nil && len(messageIDs) > 0 {
+				readMessages, err := c.hub.readAcker(context.Background(), c.userID, wsMsg.ChatID, messageIDs)
+				if err != nil {
+					log.Printf("Failed to ack messages: %v", err)
+					c.replyErr(reqID, err)
+					continue
+				}
+				for _, message := range readMessages {
+					c.hub.SendReceipt(WSMsgReadType, message, c.userID, wsMsg.Timestamp)
+				}
+			}
+			c.replyAck(reqID, nil)
 		}
+	}
+}
 
-		// 其他类型消息直接广播
-		c.hub.broadcast <- &wsMsg
+// replyAck 回复请求处理成功，saved 不为空时带上持久化后的消息ID和序列号
+func (c *Client) replyAck(reqID string, saved *WSMessage) {
+	reply := &WSMessage{
+		Type:      WSAckType,
+		ReqID:     reqID,
+		Timestamp: time.Now(),
+	}
+	if saved != nil {
+		reply.ChatID = saved.ChatID
+		reply.MessageID = saved.MessageID
+		reply.SeqID = saved.SeqID
+	}
+	c.trySend(c.hub.encodeMessage(reply), 0)
+}
+
+// replyErr 回复请求处理失败，错误码与 REST 接口一致
+func (c *Client) replyErr(reqID string, err error) {
+	code, message := service.ErrorCode(err)
+	c.replyError(reqID, code, message)
+}
+
+// replyError 回复请求处理失败
+func (c *Client) replyError(reqID string, code int, message string) {
+	c.trySend(c.hub.encodeMessage(&WSMessage{
+		Type:      WSErrorType,
+		ReqID:     reqID,
+		Code:      code,
+		Error:     message,
+		Timestamp: time.Now(),
+	}), 0)
+}
+
+// closeWithError 回复错误后断开连接
+// 错误帧和关闭请求都经由发送缓冲区，保证错误帧先于关闭帧写出
+func (c *Client) closeWithError(code int, message string) {
+	data := c.hub.encodeMessage(&WSMessage{
+		Type:      WSErrorType,
+		Code:      code,
+		Error:     message,
+		Timestamp: time.Now(),
+	})
+	if c.sendBlocking(data) {
+		c.sendBlocking(nil)
 	}
 }
 
@@ -438,9 +1042,13 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case <-c.done:
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case message := <-c.send:
+			// nil 表示 closeWithError 请求断开连接
+			if message == nil {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""))
 				return
 			}
 
@@ -0,0 +1,570 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/forever-free1/telegram-go/backend/internal/model"
+	"github.com/forever-free1/telegram-go/backend/internal/service"
+)
+
+func newTestClient(hub *Hub, userID int64, connID string, chatIDs ...int64) *Client {
+	return &Client{
+		hub:     hub,
+		send:    make(chan []byte, 16),
+		userID:  userID,
+		connID:  connID,
+		chatIDs: chatIDs,
+		done:    make(chan struct{}),
+	}
+}
+
+// receiveFrame 接收并解码一条消息
+func receiveFrame(t *testing.T, c *Client) *WSMessage {
+	t.Helper()
+	var msg WSMessage
+	assert.NoError(t, json.Unmarshal(receive(t, c), &msg))
+	return &msg
+}
+
+// assertNothing 断言连接没有收到任何消息
+func assertNothing(t *testing.T, c *Client) {
+	t.Helper()
+	select {
+	case data := <-c.send:
+		t.Fatalf("conn %s unexpectedly received %s", c.connID, data)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// waitFor 等待 Hub 的异步注册/注销完成
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
+func receive(t *testing.T, c *Client) []byte {
+	t.Helper()
+	select {
+	case data := <-c.send:
+		return data
+	case <-time.After(time.Second):
+		t.Fatalf("conn %s received nothing", c.connID)
+		return nil
+	}
+}
+
+func TestHub_MultiDevice(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	phone := newTestClient(hub, 1, "phone", 10)
+	desktop := newTestClient(hub, 1, "desktop", 10)
+	hub.register <- phone
+	hub.register <- desktop
+	waitFor(t, func() bool { return len(hub.GetClients(1)) == 2 })
+
+	hub.SendToUser(1, &WSMessage{Type: "ping"})
+	assert.NotEmpty(t, receive(t, phone))
+	assert.NotEmpty(t, receive(t, desktop))
+
+	hub.JoinChat(phone, 10)
+	hub.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 10})
+	assert.NotEmpty(t, receive(t, phone))
+	assert.NotEmpty(t, receive(t, desktop))
+
+	// 只注销关闭的连接
+	hub.unregister <- phone
+	waitFor(t, func() bool { return len(hub.GetClients(1)) == 1 })
+	assert.True(t, hub.IsUserOnline(1))
+
+	hub.SendToUser(1, &WSMessage{Type: "ping"})
+	assert.NotEmpty(t, receive(t, desktop))
+
+	hub.unregister <- desktop
+	waitFor(t, func() bool { return !hub.IsUserOnline(1) })
+}
+
+func TestHub_SubscribesToAllChats(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	alice := newTestClient(hub, 1, "alice", 10, 20)
+	bob := newTestClient(hub, 2, "bob", 10)
+	hub.register <- alice
+	hub.register <- bob
+	waitFor(t, func() bool { return hub.IsUserOnline(1) && hub.IsUserOnline(2) })
+
+	// 未发送 join_chat 也能收到所有聊天室的消息
+	hub.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 20})
+	assert.NotEmpty(t, receive(t, alice))
+	assertNothing(t, bob)
+
+	hub.OnMemberAdded(20, 2)
+	hub.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 20})
+	assert.NotEmpty(t, receive(t, alice))
+	assert.NotEmpty(t, receive(t, bob))
+
+	// 正在输入只发给正在查看该聊天室的其他成员
+	hub.JoinChat(bob, 20)
+	hub.broadcastTyping(&WSMessage{Type: WSTypingType, ChatID: 20}, alice)
+	assert.NotEmpty(t, receive(t, bob))
+	assertNothing(t, alice)
+
+	hub.OnMemberRemoved(20, 2)
+	hub.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 20})
+	assert.NotEmpty(t, receive(t, alice))
+	assertNothing(t, bob)
+	assert.Zero(t, hub.viewingChat(bob))
+}
+
+func TestHub_CrossNodeFanOut(t *testing.T) {
+	broker := NewMemoryBroker()
+	nodeA := NewHub()
+	nodeA.SetBroker(broker, "a")
+	nodeB := NewHub()
+	nodeB.SetBroker(broker, "b")
+	go nodeA.Run()
+	go nodeB.Run()
+
+	alice := newTestClient(nodeA, 1, "alice", 10)
+	bob := newTestClient(nodeB, 2, "bob", 10)
+	nodeA.register <- alice
+	nodeB.register <- bob
+	waitFor(t, func() bool { return nodeA.IsUserOnline(2) && nodeB.IsUserOnline(1) })
+
+	// 等待两个节点都完成订阅
+	waitFor(t, func() bool {
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+		return len(broker.handlers) == 2
+	})
+
+	nodeA.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 10})
+	assert.NotEmpty(t, receive(t, alice))
+	assert.NotEmpty(t, receive(t, bob))
+
+	nodeA.SendToUser(2, &WSMessage{Type: "ping"})
+	assert.NotEmpty(t, receive(t, bob))
+	assertNothing(t, alice)
+
+	// 成员变更在任意节点触发都会同步到持有连接的节点
+	nodeA.OnMemberAdded(20, 2)
+	nodeA.broadcastToChat(&WSMessage{Type: WSMessageType, ChatID: 20})
+	assert.NotEmpty(t, receive(t, bob))
+
+	nodeB.unregister <- bob
+	waitFor(t, func() bool { return !nodeA.IsUserOnline(2) })
+}
+
+func TestHub_ResumeReplaysBeforeLiveDelivery(t *testing.T) {
+	hub := NewHub()
+	hub.SetMessageReplayer(func(ctx context.Context, userID, lastSeqID int64) (*service.SyncResponse, error) {
+		assert.Equal(t, int64(100), lastSeqID)
+		return &service.SyncResponse{
+			Messages: []*model.Message{
+				{ID: 1, SeqID: 101, ChatID: 10},
+				{ID: 2, SeqID: 102, ChatID: 10},
+			},
+			SeqID: 102,
+		}, nil
+	})
+
+	client := newTestClient(hub, 1, "phone", 10)
+	client.resuming = true
+
+	// 补发期间到达的实时消息，其中 102 与补发内容重复
+	client.trySend(hub.encodeMessage(&WSMessage{Type: WSMessageType, SeqID: 102}), 102)
+	client.trySend(hub.encodeMessage(&WSMessage{Type: WSMessageType, SeqID: 103}), 103)
+	assertNothing(t, client)
+
+	client.replay(100)
+
+	assert.Equal(t, int64(101), receiveFrame(t, client).SeqID)
+	assert.Equal(t, int64(102), receiveFrame(t, client).SeqID)
+	assert.Equal(t, int64(103), receiveFrame(t, client).SeqID)
+	resumed := receiveFrame(t, client)
+	assert.Equal(t, WSResumedType, resumed.Type)
+	assert.Equal(t, int64(102), resumed.SeqID)
+
+	// 补发完成后直接实时投递
+	client.trySend(hub.encodeMessage(&WSMessage{Type: WSMessageType, SeqID: 104}), 104)
+	assert.Equal(t, int64(104), receiveFrame(t, client).SeqID)
+}
+
+func TestHub_ResumeFailureClosesConnection(t *testing.T) {
+	hub := NewHub()
+	hub.SetMessageReplayer(func(ctx context.Context, userID, lastSeqID int64) (*service.SyncResponse, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	client := newTestClient(hub, 1, "phone", 10)
+	client.resuming = true
+	client.trySend(hub.encodeMessage(&WSMessage{Type: WSMessageType, SeqID: 103}), 103)
+
+	client.replay(100)
+
+	// 补发失败时回复错误并请求断开，不发送 WS_RESUMED 和缓存的实时消息
+	reply := receiveFrame(t, client)
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, 500, reply.Code)
+	assert.Nil(t, receive(t, client))
+	assertNothing(t, client)
+}
+
+func TestServeWS_ResumeFailureClosesConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := NewHub()
+	hub.SetMessageReplayer(func(ctx context.Context, userID, lastSeqID int64) (*service.SyncResponse, error) {
+		return nil, errors.New("database unavailable")
+	})
+	go hub.Run()
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?last_seq_id=100", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var reply WSMessage
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, 500, reply.Code)
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseInternalServerErr), "unexpected error: %v", err)
+	waitFor(t, func() bool { return !hub.IsUserOnline(1) })
+}
+
+func TestServeWS_RejectsWhenChatsFailToLoad(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := NewHub()
+	hub.SetChatIDsLoader(func(ctx context.Context, userID int64) ([]int64, error) {
+		return nil, errors.New("database unavailable")
+	})
+	go hub.Run()
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// 加载聊天室失败时拒绝升级，不注册一个收不到任何消息的连接
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.False(t, hub.IsUserOnline(1))
+}
+
+func TestServeWS_RepliesToEveryFrame(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := NewHub()
+	hub.SetChatIDsLoader(func(ctx context.Context, userID int64) ([]int64, error) {
+		return []int64{10}, nil
+	})
+	hub.SetMessageSaver(func(ctx context.Context, msg *WSMessage) (*model.Message, bool, error) {
+		if msg.ChatID != 10 {
+			return nil, false, service.ErrNotAuthorized
+		}
+		return &model.Message{ID: 7, SeqID: 700, ChatID: msg.ChatID}, true, nil
+	})
+	go hub.Run()
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// 跳过广播，只读取回复
+	readReply := func() *WSMessage {
+		for {
+			var msg WSMessage
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg.Type == WSAckType || msg.Type == WSErrorType {
+				return &msg
+			}
+		}
+	}
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{not json")))
+	reply := readReply()
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, 400, reply.Code)
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSMessageType, ReqID: "r1", ChatID: 10, Content: "hi"}))
+	reply = readReply()
+	assert.Equal(t, WSAckType, reply.Type)
+	assert.Equal(t, "r1", reply.ReqID)
+	assert.Equal(t, int64(7), reply.MessageID)
+	assert.Equal(t, int64(700), reply.SeqID)
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSMessageType, ReqID: "r2", ChatID: 99, Content: "hi"}))
+	reply = readReply()
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, "r2", reply.ReqID)
+	assert.Equal(t, 403, reply.Code)
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: "join_chat", ReqID: "r3", ChatID: 99}))
+	reply = readReply()
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, "r3", reply.ReqID)
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSTypingType, ReqID: "r4", ChatID: 10}))
+	reply = readReply()
+	assert.Equal(t, WSAckType, reply.Type)
+	assert.Equal(t, "r4", reply.ReqID)
+}
+
+func TestServeWS_DeliveryReceiptNotifiesSender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deliveredAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hub := NewHub()
+	hub.SetDeliveryAcker(func(ctx context.Context, userID int64, messageIDs []int64) ([]*model.Message, error) {
+		assert.Equal(t, int64(1), userID)
+		assert.ElementsMatch(t, []int64{7, 8}, messageIDs)
+		// 8 之前已经送达过，不再通知
+		return []*model.Message{{ID: 7, SeqID: 700, ChatID: 10, SenderID: 2, DeliveredAt: &deliveredAt}}, nil
+	})
+	go hub.Run()
+
+	sender := newTestClient(hub, 2, "sender", 10)
+	hub.register <- sender
+	waitFor(t, func() bool { return hub.IsUserOnline(2) })
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSMsgDeliveredType, ReqID: "d1", MessageID: 8, MessageIDs: []int64{7}}))
+	var reply WSMessage
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSAckType, reply.Type)
+	assert.Equal(t, "d1", reply.ReqID)
+
+	receipt := receiveFrame(t, sender)
+	assert.Equal(t, WSMsgDeliveredType, receipt.Type)
+	assert.Equal(t, int64(7), receipt.MessageID)
+	assert.Equal(t, int64(10), receipt.ChatID)
+	assert.Equal(t, int64(1), receipt.SenderID)
+	assert.True(t, deliveredAt.Equal(receipt.Timestamp))
+	assertNothing(t, sender)
+}
+
+func TestServeWS_ReadReceiptIsConfirmedByServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := NewHub()
+	hub.SetChatIDsLoader(func(ctx context.Context, userID int64) ([]int64, error) {
+		return []int64{10}, nil
+	})
+	hub.SetReadAcker(func(ctx context.Context, userID, chatID int64, messageIDs []int64) ([]*model.Message, error) {
+		assert.Equal(t, int64(1), userID)
+		assert.Equal(t, int64(10), chatID)
+		// 只有 7 是其他成员发送的消息，伪造的 8 不会被确认
+		return []*model.Message{{ID: 7, SeqID: 700, ChatID: 10, SenderID: 2}}, nil
+	})
+	go hub.Run()
+
+	sender := newTestClient(hub, 2, "sender", 10)
+	member := newTestClient(hub, 3, "member", 10)
+	hub.register <- sender
+	hub.register <- member
+	waitFor(t, func() bool { return hub.IsUserOnline(2) && hub.IsUserOnline(3) })
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitFor(t, func() bool { return hub.IsUserOnline(1) })
+
+	readReply := func() *WSMessage {
+		var reply WSMessage
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		require.NoError(t, conn.ReadJSON(&reply))
+		return &reply
+	}
+
+	// 不在聊天室中时不会调用 Service
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSMsgReadType, ReqID: "r1", ChatID: 99, MessageID: 7}))
+	reply := readReply()
+	assert.Equal(t, WSErrorType, reply.Type)
+	assert.Equal(t, 403, reply.Code)
+
+	require.NoError(t, conn.WriteJSON(&WSMessage{Type: WSMsgReadType, ReqID: "r2", ChatID: 10, MessageIDs: []int64{7, 8}}))
+	reply = readReply()
+	assert.Equal(t, WSAckType, reply.Type)
+	assert.Equal(t, "r2", reply.ReqID)
+
+	receipt := receiveFrame(t, sender)
+	assert.Equal(t, WSMsgReadType, receipt.Type)
+	assert.Equal(t, int64(7), receipt.MessageID)
+	assert.Equal(t, int64(1), receipt.SenderID)
+	assertNothing(t, sender)
+	assertNothing(t, member)
+}
+
+func TestServeWS_RejectsServerOnlyEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	hub := NewHub()
+	hub.SetChatIDsLoader(func(ctx context.Context, userID int64) ([]int64, error) {
+		return []int64{10}, nil
+	})
+	go hub.Run()
+
+	member := newTestClient(hub, 2, "member", 10)
+	hub.register <- member
+	waitFor(t, func() bool { return hub.IsUserOnline(2) })
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitFor(t, func() bool { return hub.IsUserOnline(1) })
+
+	// 客户端伪造的服务端事件不会转发给其他成员
+	for _, eventType := range []string{service.EventMessageEdited, service.EventMessageDeleted, "unknown"} {
+		require.NoError(t, conn.WriteJSON(&WSMessage{Type: eventType, ReqID: eventType, ChatID: 10, MessageID: 7, Content: "forged"}))
+		var reply WSMessage
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		require.NoError(t, conn.ReadJSON(&reply))
+		assert.Equal(t, WSErrorType, reply.Type)
+		assert.Equal(t, eventType, reply.ReqID)
+		assert.Equal(t, 400, reply.Code)
+	}
+	assertNothing(t, member)
+}
+
+func TestServeWS_BroadcastsSavedMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hub := NewHub()
+	hub.SetChatIDsLoader(func(ctx context.Context, userID int64) ([]int64, error) {
+		return []int64{10}, nil
+	})
+	hub.SetMessageSaver(func(ctx context.Context, msg *WSMessage) (*model.Message, bool, error) {
+		return &model.Message{
+			ID:        7,
+			SeqID:     700,
+			ChatID:    msg.ChatID,
+			SenderID:  msg.SenderID,
+			Type:      1,
+			Content:   msg.Content,
+			Signature: "Alice",
+			CreatedAt: createdAt,
+		}, true, nil
+	})
+	go hub.Run()
+
+	member := newTestClient(hub, 2, "member", 10)
+	hub.register <- member
+	waitFor(t, func() bool { return hub.IsUserOnline(2) })
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("user", &service.UserClaims{UserID: 1})
+	}, ServeWS(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// 客户端伪造的转发来源、签名和服务消息操作不会被广播
+	require.NoError(t, conn.WriteJSON(&WSMessage{
+		Type:                WSMessageType,
+		ReqID:               "r1",
+		ChatID:              10,
+		MsgType:             1,
+		Content:             "hi",
+		Signature:           "forged",
+		Action:              "chat_created",
+		ForwardedFromChatID: 99,
+	}))
+
+	frame := receiveFrame(t, member)
+	assert.Equal(t, WSMessageType, frame.Type)
+	assert.Empty(t, frame.ReqID)
+	assert.Equal(t, int64(7), frame.MessageID)
+	assert.Equal(t, int64(700), frame.SeqID)
+	assert.Equal(t, int64(1), frame.SenderID)
+	assert.Equal(t, "hi", frame.Content)
+	assert.Equal(t, "Alice", frame.Signature)
+	assert.Empty(t, frame.Action)
+	assert.Zero(t, frame.ForwardedFromChatID)
+	assert.True(t, createdAt.Equal(frame.Timestamp))
+}
+
+func TestWSMessage_PollRequest(t *testing.T) {
+	var msg WSMessage
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"message","chat_id":10,"msg_type":6,"poll":{"question":"2+2?","options":["3","4"],"is_quiz":true,"correct_option":1}}`), &msg))
+
+	req := msg.PollRequest()
+	require.NotNil(t, req)
+	assert.Equal(t, "2+2?", req.Question)
+	assert.Equal(t, []string{"3", "4"}, req.Options)
+	assert.True(t, req.IsQuiz)
+	assert.Equal(t, 1, req.CorrectOption)
+
+	assert.Nil(t, (&WSMessage{Type: WSMessageType}).PollRequest())
+}
@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MemoryBroker 进程内的 Broker 实现
+// 多个 Hub 共享同一个实例即可模拟多节点部署，主要用于测试
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(env *Envelope)
+	nextID   int
+	presence map[int64]map[string]bool // userID -> nodeID -> online
+}
+
+// NewMemoryBroker 创建进程内 Broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		handlers: make(map[int]func(env *Envelope)),
+		presence: make(map[int64]map[string]bool),
+	}
+}
+
+// Publish 同步投递给所有订阅者
+// 经过一次 JSON 编解码，保证与 Redis 实现一样不共享内存
+func (b *MemoryBroker) Publish(ctx context.Context, env *Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	handlers := make([]func(env *Envelope), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		var copied Envelope
+		if err := json.Unmarshal(data, &copied); err != nil {
+			return err
+		}
+		handler(&copied)
+	}
+	return nil
+}
+
+// Subscribe 注册订阅者，直到 ctx 结束
+func (b *MemoryBroker) Subscribe(ctx context.Context, handler func(env *Envelope)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers, id)
+	b.mu.Unlock()
+	return nil
+}
+
+// SetOnline 更新用户在指定节点上的在线状态
+func (b *MemoryBroker) SetOnline(ctx context.Context, nodeID string, userID int64, online bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if online {
+		if b.presence[userID] == nil {
+			b.presence[userID] = make(map[string]bool)
+		}
+		b.presence[userID][nodeID] = true
+		return nil
+	}
+
+	if nodes := b.presence[userID]; nodes != nil {
+		delete(nodes, nodeID)
+		if len(nodes) == 0 {
+			delete(b.presence, userID)
+		}
+	}
+	return nil
+}
+
+// IsOnline 检查用户是否在任意节点上在线
+func (b *MemoryBroker) IsOnline(ctx context.Context, userID int64) (bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.presence[userID]) > 0, nil
+}
+
+// Close 释放资源
+func (b *MemoryBroker) Close() error {
+	return nil
+}
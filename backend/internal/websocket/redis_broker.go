@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/forever-free1/telegram-go/backend/internal/config"
+)
+
+const (
+	// defaultBrokerChannel 默认的 Redis 发布订阅频道
+	defaultBrokerChannel = "telegram-go:ws"
+	// nodeTTL 节点存活标记的过期时间，节点宕机后其在线用户随之失效
+	nodeTTL = 30 * time.Second
+)
+
+// RedisBroker 基于 Redis 发布订阅的 Broker 实现
+//
+// 在线状态存储:
+//   - ws:presence:{userID} (Hash): nodeID -> 1，用户在哪些节点上有连接
+//   - ws:node:{nodeID} (String): 节点存活标记，由持有连接的节点定期续期
+type RedisBroker struct {
+	client    *redis.Client
+	channel   string
+	heartbeat sync.Once
+	cancel    context.CancelFunc
+}
+
+// NewRedisBroker 创建 Redis Broker
+func NewRedisBroker(cfg *config.RedisConfig, channel string) (*RedisBroker, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect redis: %w", err)
+	}
+
+	if channel == "" {
+		channel = defaultBrokerChannel
+	}
+
+	return &RedisBroker{
+		client:  client,
+		channel: channel,
+	}, nil
+}
+
+func presenceKey(userID int64) string {
+	return "ws:presence:" + strconv.FormatInt(userID, 10)
+}
+
+func nodeKey(nodeID string) string {
+	return "ws:node:" + nodeID
+}
+
+// Publish 发布消息信封给其他节点
+func (b *RedisBroker) Publish(ctx context.Context, env *Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe 接收其他节点发布的消息信封，阻塞直到 ctx 结束
+// 连接断开时由 go-redis 自动重连并重新订阅
+func (b *RedisBroker) Subscribe(ctx context.Context, handler func(env *Envelope)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var env Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("Failed to unmarshal envelope: %v", err)
+				continue
+			}
+			handler(&env)
+		}
+	}
+}
+
+// SetOnline 更新用户在指定节点上的在线状态
+// 首次上线时启动节点存活标记的续期
+func (b *RedisBroker) SetOnline(ctx context.Context, nodeID string, userID int64, online bool) error {
+	if !online {
+		return b.client.HDel(ctx, presenceKey(userID), nodeID).Err()
+	}
+
+	b.startHeartbeat(nodeID)
+
+	pipe := b.client.TxPipeline()
+	pipe.HSet(ctx, presenceKey(userID), nodeID, 1)
+	pipe.Set(ctx, nodeKey(nodeID), 1, nodeTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsOnline 检查用户是否在任意存活节点上在线
+// 顺带清理已宕机节点留下的在线记录
+func (b *RedisBroker) IsOnline(ctx context.Context, userID int64) (bool, error) {
+	nodeIDs, err := b.client.HKeys(ctx, presenceKey(userID)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	for _, nodeID := range nodeIDs {
+		alive, err := b.client.Exists(ctx, nodeKey(nodeID)).Result()
+		if err != nil {
+			return false, err
+		}
+		if alive > 0 {
+			return true, nil
+		}
+		b.client.HDel(ctx, presenceKey(userID), nodeID)
+	}
+	return false, nil
+}
+
+// startHeartbeat 定期续期节点存活标记，只启动一次
+func (b *RedisBroker) startHeartbeat(nodeID string) {
+	b.heartbeat.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.cancel = cancel
+		go b.refreshNode(ctx, nodeID)
+	})
+}
+
+// refreshNode 续期节点存活标记直到 ctx 结束
+func (b *RedisBroker) refreshNode(ctx context.Context, nodeID string) {
+	ticker := time.NewTicker(nodeTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.client.Set(ctx, nodeKey(nodeID), 1, nodeTTL).Err(); err != nil {
+				log.Printf("Failed to refresh node %s: %v", nodeID, err)
+			}
+		}
+	}
+}
+
+// Close 释放资源
+func (b *RedisBroker) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return b.client.Close()
+}